@@ -2,12 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/Redarek/go-tg-bot-rest/pkg/health"
+	"github.com/Redarek/go-tg-bot-rest/pkg/logging"
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/queue"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
 	"github.com/Redarek/go-tg-bot-rest/pkg/services"
+	"github.com/Redarek/go-tg-bot-rest/pkg/workers"
 	"golang.org/x/time/rate"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/Redarek/go-tg-bot-rest/pkg/config"
 	"github.com/Redarek/go-tg-bot-rest/pkg/db"
@@ -17,85 +30,325 @@ import (
 )
 
 func main() {
-	cfg := config.LoadConfig()
-	if cfg.TelegramToken == "" {
-		log.Fatal("TELEGRAM_APITOKEN not found in config")
+	cfg, cfgErr := config.Load()
+
+	logger := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(logger)
+	_ = tgbotapi.SetLogger(logging.BotLogger{L: logger})
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "config":
+			os.Exit(configCmd(cfg, cfgErr, os.Args[2:]))
+		case "healthcheck":
+			// пробе нужен только HEALTH_ADDR — остальной конфиг не проверяем
+			os.Exit(healthcheck(cfg, logger, os.Args[2:]))
+		}
+	}
+
+	if cfgErr != nil {
+		logConfigErrors(logger, cfgErr)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate(cfg, logger, os.Args[2:]))
 	}
 
 	bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
-		log.Fatalf("Telegram init error: %v", err)
-	}
-	log.Printf("Authorized as @%s", bot.Self.UserName)
-
-	pub := tgbotapi.NewSetMyCommands(
-		tgbotapi.BotCommand{Command: "start", Description: "Начать работу"},
-		tgbotapi.BotCommand{Command: "draw", Description: "Получить скидку"},
-	)
-	publicScope := tgbotapi.NewBotCommandScopeDefault()
-	pub.Scope = &publicScope
-	_, _ = bot.Request(pub)
-
-	admin := tgbotapi.NewSetMyCommands(
-		tgbotapi.BotCommand{Command: "start", Description: "Начать работу"},
-		tgbotapi.BotCommand{Command: "promotions", Description: "Список скидок"},
-		tgbotapi.BotCommand{Command: "addpromotion", Description: "Добавить скидку"},
-	)
-	adminScope := tgbotapi.NewBotCommandScopeChat(cfg.AdminID)
-	admin.Scope = &adminScope
-	_, _ = bot.Request(admin)
-
-	pool := db.Connect(cfg)
+		logger.Error("telegram init failed", "err", err)
+		os.Exit(1)
+	}
+	logger.Info("authorized", "bot", bot.Self.UserName)
+
+	pool, err := db.Connect(cfg, logger)
+	if err != nil {
+		logger.Error("database init failed", "err", err)
+		os.Exit(1)
+	}
 	defer pool.Close()
 
-	// Глобальный лимит Telegram. Ставим «безопасные» ~28 rps.
-	lim := rate.NewLimiter(rate.Limit(28), 28)
-	sender := services.NewSender(bot, lim)
+	// Глобальный лимит Telegram (по умолчанию «безопасные» ~28 rps)
+	lim := rate.NewLimiter(rate.Limit(cfg.RateLimit), max(int(cfg.RateLimit), 1))
+	sender := services.NewSender(bot, lim, cfg.BulkShare, logger)
+	metrics.Publish("sender_queues", func() any { return sender.QueueLengths() })
 
-	h := handlers.NewHandler(bot, sender, pool, cfg)
+	repo := repositories.NewRepository(pool, logger)
+	h := handlers.NewHandler(sender, repo, cfg, logger)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	h.SetCommands(ctx)
+
+	// Продолжаем с сохранённого offset, а не с начала
+	lastID, err := repo.LastUpdateID(ctx)
+	if err != nil {
+		logger.Error("load last update_id", "err", err)
+		os.Exit(1)
+	}
 	u := tgbotapi.NewUpdate(0)
+	if lastID > 0 {
+		u.Offset = int(lastID) + 1
+	}
 	u.Timeout = 60
-	u.AllowedUpdates = []string{"message", "callback_query"} // меньше шума
+	u.AllowedUpdates = []string{"message", "callback_query", "my_chat_member"} // меньше шума
 	updates := bot.GetUpdatesChan(u)
+	logger.Info("polling started", "offset", u.Offset)
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
-	// Пул воркеров + очередь (бэкпрешер)
-	const workers = 64
-	jobs := make(chan tgbotapi.Update, 4096)
-	for i := 0; i < workers; i++ {
-		go func() {
-			for upd := range jobs {
-				// защита от паник внутри обработчика
-				func() {
-					defer func() { _ = recover() }()
-					h.HandleUpdate(upd)
-				}()
+	// Отметки обработанных апдейтов нужны, пока Telegram может их повторить
+	go func() {
+		t := time.NewTicker(time.Hour)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if n, err := repo.PruneProcessedUpdates(ctx, 48*time.Hour); err != nil {
+					logger.Error("prune processed updates", "err", err)
+				} else if n > 0 {
+					logger.Debug("processed updates pruned", "count", n)
+				}
 			}
-		}()
-	}
+		}
+	}()
+
+	// Поллер → очередь в Postgres → пул воркеров, шардированный по пользователю:
+	// апдейты одного юзера идут строго по порядку, разные юзеры — параллельно.
+	// Апдейт переживает рестарт и переполнение и удаляется только после обработки.
+	q := queue.New(repo, h.HandleUpdate, h.UpdateFailed, cfg.HandlerTimeout, logger)
+	wp := workers.NewPool(cfg.Workers, cfg.QueueSize, q.Process)
+	wp.Start()
+	queueDone := make(chan struct{})
+	go func() {
+		defer close(queueDone)
+		q.Run(ctx, wp)
+	}()
 
-	log.Println("Bot started")
+	// Доставка призов и прочих отложенных сообщений из outbox
+	outbox := services.NewOutbox(repo, sender, logger)
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+		outbox.Run(ctx)
+	}()
+
+	hc := health.NewChecker(3 * time.Second)
+	hc.Add("db", pool.Ping)
+	hc.AddOptional("telegram", func(ctx context.Context) error {
+		_, err := sender.GetMe(services.WithPriority(ctx, services.Bulk))
+		return err
+	})
+	hc.Add("workers", func(context.Context) error {
+		if wp.Running() != wp.Size() {
+			return errors.New("worker pool is not running")
+		}
+		return nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/", hc.Handler())
+	mux.Handle("/debug/vars", metrics.Handler())
+	srv := &http.Server{Addr: cfg.HealthAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("health server", "err", err)
+		}
+	}()
 
+	logger.Info("bot started", "workers", cfg.Workers, "health_addr", cfg.HealthAddr)
+
+loop:
 	for {
 		select {
 		case <-ctx.Done():
-			close(jobs)
-			return
+			break loop
 		case upd, ok := <-updates:
 			if !ok {
-				log.Println("updates channel closed")
-				close(jobs)
-				return
+				logger.Warn("updates channel closed")
+				break loop
 			}
-			select {
-			case jobs <- upd:
-			default:
-				// Очередь переполнена — дропнем событие (или можно считать метрику)
-				log.Println("updates backlog overflow, dropping update")
+			pushUpdate(ctx, q, upd, logger)
+		}
+	}
+
+	// Остановка: сначала перестаём брать новое, затем дожидаемся начатого
+	logger.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+	stop()
+	bot.StopReceivingUpdates()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// то, что поллер уже получил, сохраняем в очередь — обработает следующий запуск
+	for drained := false; !drained; {
+		select {
+		case upd, ok := <-updates:
+			if !ok {
+				drained = true
+				break
 			}
+			pushUpdate(drainCtx, q, upd, logger)
+		default:
+			drained = true
+		}
+	}
+
+	<-queueDone
+	if err := wp.Shutdown(drainCtx); err != nil {
+		logger.Warn("worker pool did not drain in time", "pending", wp.Pending(), "err", err)
+	}
+	select {
+	case <-outboxDone:
+	case <-drainCtx.Done():
+		logger.Warn("outbox did not finish in time; pending messages stay in the outbox")
+	}
+	sender.Close()
+	_ = srv.Shutdown(drainCtx)
+	logger.Info("bot stopped")
+}
+
+// configCmd — подкоманда `bot config check`: проверяет конфиг и печатает
+// все проблемы разом, не подключаясь к Telegram и БД
+func configCmd(cfg *config.Config, cfgErr error, args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: bot config check")
+		return 2
+	}
+	if cfgErr != nil {
+		fmt.Println("config is invalid:")
+		for _, line := range strings.Split(cfgErr.Error(), "\n") {
+			fmt.Println("  - " + line)
+		}
+		return 1
+	}
+	fmt.Println("config OK")
+	fmt.Printf("  database:  %s (pool %d..%d)\n", cfg.RedactedDSN(), cfg.DBMinConns, cfg.DBMaxConns)
+	fmt.Printf("  workers:   %d (queue %d)\n", cfg.Workers, cfg.QueueSize)
+	fmt.Printf("  telegram:  %.1f rps, bulk share %.2f\n", cfg.RateLimit, cfg.BulkShare)
+	fmt.Printf("  per user:  %.1f updates/s, burst %d\n", cfg.UserRateLimit, cfg.UserRateBurst)
+	fmt.Printf("  timeouts:  handler %s, shutdown %s\n", cfg.HandlerTimeout, cfg.ShutdownTimeout)
+	fmt.Printf("  health:    %s\n", cfg.HealthAddr)
+	if cfg.SubChannelID == 0 {
+		fmt.Println("  subscription check: off")
+	} else {
+		fmt.Printf("  subscription check: %d\n", cfg.SubChannelID)
+	}
+	return 0
+}
+
+func logConfigErrors(logger *slog.Logger, err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		logger.Error("config", "err", line)
+	}
+	logger.Error("invalid configuration; run `bot config check` for details")
+}
+
+// healthcheck — подкоманда для Docker HEALTHCHECK: `bot healthcheck [live|ready]`
+func healthcheck(cfg *config.Config, logger *slog.Logger, args []string) int {
+	path := "/readyz"
+	if len(args) > 0 && args[0] == "live" {
+		path = "/healthz"
+	}
+
+	host, port, err := net.SplitHostPort(cfg.HealthAddr)
+	if err != nil {
+		logger.Error("healthcheck", "err", err)
+		return 1
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := health.Probe(ctx, "http://"+net.JoinHostPort(host, port)+path); err != nil {
+		logger.Error("healthcheck", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// migrate — подкоманда `bot migrate up | down [N] | status` для ручного управления схемой
+func migrate(cfg *config.Config, logger *slog.Logger, args []string) int {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	pool, err := db.Open(cfg)
+	if err != nil {
+		logger.Error("migrate", "err", err)
+		return 1
+	}
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch cmd {
+	case "up":
+		n, err := db.MigrateUp(ctx, pool, logger)
+		if err != nil {
+			logger.Error("migrate up", "err", err)
+			return 1
+		}
+		logger.Info("migrate up done", "applied", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				logger.Error("migrate down: steps must be a positive number", "steps", args[1])
+				return 2
+			}
+			steps = n
+		}
+		n, err := db.MigrateDown(ctx, pool, steps, logger)
+		if err != nil {
+			logger.Error("migrate down", "err", err)
+			return 1
+		}
+		logger.Info("migrate down done", "reverted", n)
+	case "status":
+		st, err := db.Status(ctx, pool)
+		if err != nil {
+			logger.Error("migrate status", "err", err)
+			return 1
+		}
+		fmt.Printf("version: %d (dirty: %t)\n", st.Version, st.Dirty)
+		for _, m := range st.Migrations {
+			mark := "pending"
+			if m.Version <= st.Version {
+				mark = "applied"
+			}
+			fmt.Printf("  %06d_%s\t%s\n", m.Version, m.Name, mark)
+		}
+	default:
+		logger.Error("unknown migrate command, want up | down [N] | status", "command", cmd)
+		return 2
+	}
+	return 0
+}
+
+// pushUpdate сохраняет апдейт в очередь, повторяя при недоступности БД:
+// поллер при этом стоит, и Telegram придерживает остальные апдейты у себя
+func pushUpdate(ctx context.Context, q *queue.Queue, upd tgbotapi.Update, logger *slog.Logger) {
+	backoff := 100 * time.Millisecond
+	for {
+		err := q.Push(ctx, upd)
+		if err == nil {
+			return
+		}
+		metrics.Inc("updates_enqueue_errors_total")
+		logger.Error("enqueue update", "update_id", upd.UpdateID, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 5*time.Second {
+			backoff *= 2
 		}
 	}
 }
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS update_queue (
+                                            update_id    BIGINT PRIMARY KEY,
+                                            shard_key    BIGINT NOT NULL,
+                                            payload      JSONB NOT NULL,
+                                            status       TEXT NOT NULL DEFAULT 'pending', -- pending | dead
+                                            attempts     INT NOT NULL DEFAULT 0,
+                                            available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                            locked_until TIMESTAMPTZ,
+                                            last_error   TEXT,
+                                            created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS update_queue_pending_idx
+    ON update_queue (shard_key, update_id) WHERE status = 'pending';
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS outbox (
+                                      id           BIGSERIAL PRIMARY KEY,
+                                      dedup_key    TEXT UNIQUE NOT NULL,
+                                      chat_id      BIGINT NOT NULL,
+                                      kind         TEXT NOT NULL,                   -- text | photo
+                                      text         TEXT NOT NULL DEFAULT '',
+                                      image_url    TEXT NOT NULL DEFAULT '',
+                                      parse_mode   TEXT NOT NULL DEFAULT '',
+                                      reply_markup JSONB,
+                                      status       TEXT NOT NULL DEFAULT 'pending', -- pending | sent | failed
+                                      send_after   TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                      attempts     INT NOT NULL DEFAULT 0,
+                                      locked_until TIMESTAMPTZ,
+                                      last_error   TEXT,
+                                      sent_at      TIMESTAMPTZ,
+                                      created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+    ON outbox (chat_id, id) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bot_state;
+DROP TABLE IF EXISTS processed_updates;
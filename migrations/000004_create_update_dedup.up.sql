@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS processed_updates (
+                                                 update_id    BIGINT PRIMARY KEY,
+                                                 processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS processed_updates_processed_at_idx ON processed_updates (processed_at);
+
+CREATE TABLE IF NOT EXISTS bot_state (
+                                         key   TEXT PRIMARY KEY,
+                                         value BIGINT NOT NULL
+);
@@ -0,0 +1,5 @@
+INSERT INTO settings (key, value)
+SELECT 'start_caption', body FROM message_templates WHERE name = 'start'
+ON CONFLICT (key) DO NOTHING;
+
+DROP TABLE IF EXISTS message_templates;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS message_templates (
+                                                 name       TEXT PRIMARY KEY,
+                                                 body       TEXT NOT NULL,
+                                                 updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Текст приветствия переехал из настроек в шаблон start
+INSERT INTO message_templates (name, body)
+SELECT 'start', value FROM settings WHERE key = 'start_caption'
+ON CONFLICT (name) DO NOTHING;
+
+DELETE FROM settings WHERE key = 'start_caption';
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS promotion_translations;
+
+DELETE FROM message_templates WHERE locale <> 'ru';
+ALTER TABLE message_templates DROP CONSTRAINT IF EXISTS message_templates_pkey;
+ALTER TABLE message_templates DROP COLUMN IF EXISTS locale;
+ALTER TABLE message_templates ADD PRIMARY KEY (name);
+
+ALTER TABLE bot_users DROP COLUMN IF EXISTS language;
@@ -0,0 +1,17 @@
+-- Язык, выбранный пользователем через /language (NULL — как в Telegram)
+ALTER TABLE bot_users ADD COLUMN IF NOT EXISTS language TEXT;
+
+-- Шаблоны хранятся по языкам; всё, что правили до этого, было на русском
+ALTER TABLE message_templates ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'ru';
+ALTER TABLE message_templates ALTER COLUMN locale DROP DEFAULT;
+ALTER TABLE message_templates DROP CONSTRAINT IF EXISTS message_templates_pkey;
+ALTER TABLE message_templates ADD PRIMARY KEY (name, locale);
+
+-- Названия и значения скидок на других языках; основной текст — в promotions
+CREATE TABLE IF NOT EXISTS promotion_translations (
+                                                      promotion_id INT  NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+                                                      locale       TEXT NOT NULL,
+                                                      name         TEXT NOT NULL,
+                                                      value        TEXT NOT NULL,
+                                                      PRIMARY KEY (promotion_id, locale)
+);
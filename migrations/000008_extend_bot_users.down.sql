@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS bot_users_username_idx;
+
+ALTER TABLE bot_users
+    DROP COLUMN IF EXISTS chat_id,
+    DROP COLUMN IF EXISTS username,
+    DROP COLUMN IF EXISTS first_name,
+    DROP COLUMN IF EXISTS last_name,
+    DROP COLUMN IF EXISTS language_code,
+    DROP COLUMN IF EXISTS start_payload,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS blocked;
+
+ALTER TABLE bot_users ALTER COLUMN first_seen_at DROP NOT NULL;
+ALTER TABLE bot_users RENAME COLUMN first_seen_at TO created_at;
@@ -0,0 +1,22 @@
+-- Профиль гостя для поиска админом. До этой миграции user_id хранил id
+-- личного чата — для лички он совпадает с id пользователя.
+ALTER TABLE bot_users RENAME COLUMN created_at TO first_seen_at;
+UPDATE bot_users SET first_seen_at = now() WHERE first_seen_at IS NULL;
+ALTER TABLE bot_users ALTER COLUMN first_seen_at SET NOT NULL;
+
+ALTER TABLE bot_users
+    ADD COLUMN IF NOT EXISTS chat_id       BIGINT,
+    ADD COLUMN IF NOT EXISTS username      TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS first_name    TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_name     TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS language_code TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS start_payload TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_seen_at  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS blocked       BOOLEAN     NOT NULL DEFAULT false;
+
+UPDATE bot_users SET chat_id = user_id, last_seen_at = first_seen_at;
+ALTER TABLE bot_users ALTER COLUMN chat_id SET NOT NULL;
+ALTER TABLE bot_users ALTER COLUMN last_seen_at SET NOT NULL;
+ALTER TABLE bot_users ALTER COLUMN last_seen_at SET DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS bot_users_username_idx ON bot_users (lower(username)) WHERE username <> '';
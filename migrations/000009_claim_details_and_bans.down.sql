@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS bans;
+
+ALTER TABLE user_claims
+    DROP COLUMN IF EXISTS promotion_id,
+    DROP COLUMN IF EXISTS promotion_name,
+    DROP COLUMN IF EXISTS promotion_value,
+    DROP COLUMN IF EXISTS claimed_at;
@@ -0,0 +1,15 @@
+-- Что именно выиграл гость и когда; название и значение — снимок на момент
+-- розыгрыша, скидку потом могут изменить или удалить
+ALTER TABLE user_claims
+    ADD COLUMN IF NOT EXISTS promotion_id    INT REFERENCES promotions (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS promotion_name  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS promotion_value TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS claimed_at      TIMESTAMPTZ; -- NULL у клеймов, сделанных до миграции
+ALTER TABLE user_claims ALTER COLUMN claimed_at SET DEFAULT now();
+
+CREATE TABLE IF NOT EXISTS bans (
+                                    user_id    BIGINT PRIMARY KEY,
+                                    reason     TEXT        NOT NULL DEFAULT '',
+                                    banned_by  BIGINT      NOT NULL,
+                                    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,12 @@
+-- Проверка «не бот» перед розыгрышем: текущая задача, число ошибок и отметка
+-- о прохождении — переживают рестарт и одинаковы для всех реплик
+CREATE TABLE IF NOT EXISTS captchas (
+    user_id    BIGINT PRIMARY KEY,
+    kind       TEXT        NOT NULL,
+    question   TEXT        NOT NULL,
+    options    TEXT[]      NOT NULL,
+    answer     TEXT        NOT NULL,
+    attempts   INT         NOT NULL DEFAULT 0,
+    passed_at  TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,21 @@
+-- Убираем только перенесённые тексты; то, что админ успел поменять, остаётся
+DELETE FROM message_templates
+WHERE locale = 'ru'
+  AND (name, body) IN (VALUES
+          ('start', '🍀<b><u>Готов испытать удачу?</u></b>
+Запускай «Фортуну Вкуса» и забирай случайную скидку в нашем ресторане!
+😋<i>Получи скидку и приходи за своим вкусным бонусом!</i>'),
+          ('already_claimed', '⚡️<u>Попытка была одна — и Фортуна уже подарила тебе особую скидку!</u>
+
+Забронируй столик на нашем сайте и воспользуйся скидкой в ресторане:
+🔹<a href="https://ketino.ru">НАШ САЙТ</a>
+🔸<a href="https://instagram.com/ketino_rest">INSTA</a>
+🔹<a href="https://vk.com/ketinorest">VKONTAKTE</a>
+🔸<a href="https://t.me/ketinorest">TELEGRAM</a>'),
+          ('prize_cta', 'Забронируй столик на нашем сайте и воспользуйся скидкой в ресторане:
+🔹<a href="https://ketino.ru">НАШ САЙТ</a>
+🔸<a href="https://instagram.com/ketino_rest">INSTA</a>
+🔹<a href="https://vk.com/ketinorest">VKONTAKTE</a>
+🔸<a href="https://t.me/ketinorest">TELEGRAM</a>'),
+          ('shop_button', 'Забронировать столик')
+    );
@@ -0,0 +1,26 @@
+-- Тексты, которые были зашиты в код до шаблонов (000006): ссылки ресторана
+-- и кнопка брони. Встроенные шаблоны теперь нейтральные, поэтому действующей
+-- установке они переносятся правками админа. Новая установка (скидок ещё нет)
+-- остаётся со встроенными текстами; уже изменённые админом шаблоны не трогаем.
+INSERT INTO message_templates (name, locale, body)
+SELECT t.name, 'ru', t.body
+FROM (VALUES
+          ('start', '🍀<b><u>Готов испытать удачу?</u></b>
+Запускай «Фортуну Вкуса» и забирай случайную скидку в нашем ресторане!
+😋<i>Получи скидку и приходи за своим вкусным бонусом!</i>'),
+          ('already_claimed', '⚡️<u>Попытка была одна — и Фортуна уже подарила тебе особую скидку!</u>
+
+Забронируй столик на нашем сайте и воспользуйся скидкой в ресторане:
+🔹<a href="https://ketino.ru">НАШ САЙТ</a>
+🔸<a href="https://instagram.com/ketino_rest">INSTA</a>
+🔹<a href="https://vk.com/ketinorest">VKONTAKTE</a>
+🔸<a href="https://t.me/ketinorest">TELEGRAM</a>'),
+          ('prize_cta', 'Забронируй столик на нашем сайте и воспользуйся скидкой в ресторане:
+🔹<a href="https://ketino.ru">НАШ САЙТ</a>
+🔸<a href="https://instagram.com/ketino_rest">INSTA</a>
+🔹<a href="https://vk.com/ketinorest">VKONTAKTE</a>
+🔸<a href="https://t.me/ketinorest">TELEGRAM</a>'),
+          ('shop_button', 'Забронировать столик')
+     ) AS t (name, body)
+WHERE EXISTS (SELECT 1 FROM promotions)
+ON CONFLICT (name, locale) DO NOTHING;
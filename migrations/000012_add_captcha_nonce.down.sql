@@ -0,0 +1 @@
+ALTER TABLE captchas DROP COLUMN IF EXISTS nonce;
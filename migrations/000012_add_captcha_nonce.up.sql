@@ -0,0 +1,2 @@
+-- Метка задачи в callback_data кнопок: ответ на прежнюю задачу отклоняется
+ALTER TABLE captchas ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
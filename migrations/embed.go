@@ -0,0 +1,9 @@
+// Package migrations встраивает SQL-миграции в бинарник
+package migrations
+
+import "embed"
+
+// FS — файлы вида 000001_name.up.sql / 000001_name.down.sql (формат golang-migrate)
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func setBase(t *testing.T) {
+	t.Helper()
+	t.Setenv("TELEGRAM_APITOKEN", "123:abc")
+	t.Setenv("ADMIN_ID", "42")
+	t.Setenv("POSTGRES_HOST", "db")
+	t.Setenv("POSTGRES_USER", "bot")
+	t.Setenv("POSTGRES_PASSWORD", "p@ss/w:rd?")
+	t.Setenv("POSTGRES_DB", "bot")
+}
+
+func TestLoadDefaults(t *testing.T) {
+	setBase(t)
+	t.Setenv("SUB_CHANNEL_ID", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.SubChannelID != 0 {
+		t.Errorf("SubChannelID = %d, want 0 (optional)", cfg.SubChannelID)
+	}
+	if cfg.DBMaxConns != 50 || cfg.Workers != 64 || cfg.QueueSize != 4096 || cfg.RateLimit != 28 ||
+		cfg.UserRateLimit != 1 || cfg.UserRateBurst != 5 {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+	want := "postgres://bot:p%40ss%2Fw%3Ard%3F@db:5432/bot?sslmode=prefer"
+	if got := cfg.DSN(); got != want {
+		t.Errorf("DSN = %q, want %q", got, want)
+	}
+	if strings.Contains(cfg.RedactedDSN(), "p%40ss") {
+		t.Errorf("RedactedDSN leaks password: %s", cfg.RedactedDSN())
+	}
+}
+
+func TestLoadDatabaseURL(t *testing.T) {
+	setBase(t)
+	t.Setenv("POSTGRES_HOST", "")
+	t.Setenv("DATABASE_URL", "postgres://u:p@remote:6432/app?sslmode=require")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DSN() != "postgres://u:p@remote:6432/app?sslmode=require" {
+		t.Errorf("DSN = %q, want DATABASE_URL as is", cfg.DSN())
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	setBase(t)
+	t.Setenv("TELEGRAM_APITOKEN", "")
+	t.Setenv("ADMIN_ID", "admin")
+	t.Setenv("SUB_CHANNEL_ID", "@channel")
+	t.Setenv("WORKERS", "0")
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	t.Setenv("SENDER_BULK_SHARE", "1.5")
+	t.Setenv("POSTGRES_SSLMODE", "maybe")
+	t.Setenv("DEFAULT_LOCALE", "klingon")
+	t.Setenv("USER_RATE_BURST", "0")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load: want error")
+	}
+	for _, key := range []string{
+		"TELEGRAM_APITOKEN", "ADMIN_ID", "SUB_CHANNEL_ID", "WORKERS",
+		"SHUTDOWN_TIMEOUT", "SENDER_BULK_SHARE", "POSTGRES_SSLMODE",
+		"DEFAULT_LOCALE", "USER_RATE_BURST",
+	} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not mention %s:\n%v", key, err)
+		}
+	}
+}
+
+func TestLoadReportsBrokenDotEnv(t *testing.T) {
+	setBase(t)
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(".env", []byte("BROKEN='unterminated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), ".env") {
+		t.Fatalf("Load with a broken .env = %v, want an .env error", err)
+	}
+}
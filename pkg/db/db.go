@@ -4,34 +4,48 @@ import (
 	"context"
 	"fmt"
 	"github.com/Redarek/go-tg-bot-rest/pkg/config"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func Connect(cfg *config.Config) *pgxpool.Pool {
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
-		cfg.PostgresUser,
-		cfg.PostgresPassword,
-		cfg.PostgresHost,
-		cfg.PostgresPort,
-		cfg.PostgresDB,
-	)
+// Connect открывает пул и применяет встроенные миграции
+func Connect(cfg *config.Config, logger *slog.Logger) (*pgxpool.Pool, error) {
+	pool, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	n, err := MigrateUp(ctx, pool, logger)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("apply migrations: %w", err)
+	}
+	if n > 0 {
+		logger.Info("migrations applied", "count", n)
+	}
+	return pool, nil
+}
 
-	pcfg, err := pgxpool.ParseConfig(dsn)
+// Open открывает пул без миграций (для подкоманды migrate)
+func Open(cfg *config.Config) (*pgxpool.Pool, error) {
+	pcfg, err := pgxpool.ParseConfig(cfg.DSN())
 	if err != nil {
-		log.Fatalf("parse pool config error: %v", err)
+		return nil, fmt.Errorf("parse database url %s: %w", cfg.RedactedDSN(), err)
 	}
 
-	pcfg.MaxConns = 50
-	pcfg.MinConns = 5
-	pcfg.MaxConnLifetime = time.Hour
+	pcfg.MaxConns = cfg.DBMaxConns
+	pcfg.MinConns = cfg.DBMinConns
+	pcfg.MaxConnLifetime = cfg.DBMaxConnLifetime
 	pcfg.HealthCheckPeriod = time.Minute
+	pcfg.ConnConfig.ConnectTimeout = cfg.DBConnectTimeout
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), pcfg)
 	if err != nil {
-		log.Fatalf("unable to connect to database: %v", err)
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
 	}
-	return pool
+	return pool, nil
 }
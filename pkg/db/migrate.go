@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Redarek/go-tg-bot-rest/migrations"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Ключ advisory-блокировки: миграции применяет одна реплика, остальные ждут
+const migrateLockKey = 0x74676d6967 // "tgmig"
+
+// Migration — пара up/down с одним номером версии
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus — состояние схемы: текущая версия и известные миграции
+type MigrationStatus struct {
+	Version    int64 // 0 — ничего не применено
+	Dirty      bool
+	Migrations []Migration
+}
+
+// LoadMigrations читает встроенные миграции, упорядоченные по версии
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrations(migrations.FS)
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, f := range files {
+		var dir string
+		switch {
+		case strings.HasSuffix(f, ".up.sql"):
+			dir = "up"
+		case strings.HasSuffix(f, ".down.sql"):
+			dir = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", f)
+		}
+		base := strings.TrimSuffix(f, "."+dir+".sql")
+		num, name, _ := strings.Cut(base, "_")
+		v, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", f, err)
+		}
+		body, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[v]
+		if m == nil {
+			m = &Migration{Version: v, Name: name}
+			byVersion[v] = m
+		}
+		if dir == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d: missing up file", m.Version)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// MigrateUp применяет все неприменённые миграции; возвращает число применённых
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) (int, error) {
+	list, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	err = withMigrateLock(ctx, pool, func(conn *pgx.Conn) error {
+		cur, dirty, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("database is dirty at version %d: fix the schema by hand and reset schema_migrations", cur)
+		}
+		for _, m := range list {
+			if m.Version <= cur {
+				continue
+			}
+			logger.Info("applying migration", "version", m.Version, "name", m.Name)
+			if err = applyMigration(ctx, conn, m.Version, m.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown откатывает steps последних применённых миграций
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int, logger *slog.Logger) (int, error) {
+	list, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	err = withMigrateLock(ctx, pool, func(conn *pgx.Conn) error {
+		cur, dirty, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("database is dirty at version %d: fix the schema by hand and reset schema_migrations", cur)
+		}
+		for i := len(list) - 1; i >= 0 && reverted < steps; i-- {
+			m := list[i]
+			if m.Version > cur {
+				continue
+			}
+			if m.Version < cur {
+				return fmt.Errorf("applied version %d is not among embedded migrations", cur)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s: missing down file", m.Version, m.Name)
+			}
+			prev := int64(0)
+			if i > 0 {
+				prev = list[i-1].Version
+			}
+			logger.Info("reverting migration", "version", m.Version, "name", m.Name)
+			if err = applyMigration(ctx, conn, prev, m.Down); err != nil {
+				return fmt.Errorf("revert %d_%s: %w", m.Version, m.Name, err)
+			}
+			cur = prev
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status — текущая версия схемы и список встроенных миграций
+func Status(ctx context.Context, pool *pgxpool.Pool) (MigrationStatus, error) {
+	list, err := LoadMigrations()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	defer conn.Release()
+
+	if err = ensureSchemaTable(ctx, conn.Conn()); err != nil {
+		return MigrationStatus{}, err
+	}
+	v, dirty, err := schemaVersion(ctx, conn.Conn())
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	return MigrationStatus{Version: v, Dirty: dirty, Migrations: list}, nil
+}
+
+// withMigrateLock держит сессионную advisory-блокировку на время fn
+func withMigrateLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgx.Conn) error) error {
+	c, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Release()
+	conn := c.Conn()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, int64(migrateLockKey)); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, int64(migrateLockKey))
+	}()
+
+	if err = ensureSchemaTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// Таблица совместима с golang-migrate: одна строка (version, dirty)
+func ensureSchemaTable(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty   BOOLEAN NOT NULL
+		)`)
+	return err
+}
+
+func schemaVersion(ctx context.Context, conn *pgx.Conn) (int64, bool, error) {
+	var v int64
+	var dirty bool
+	err := conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&v, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	return v, dirty, err
+}
+
+// applyMigration выполняет SQL и переводит схему на версию target одной
+// транзакцией: при ошибке схема и номер версии остаются прежними
+func applyMigration(ctx context.Context, conn *pgx.Conn, target int64, sql string) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		if target == 0 {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, target)
+		return err
+	})
+}
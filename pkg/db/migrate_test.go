@@ -0,0 +1,37 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	list, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, m := range list {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration #%d has version %d, want sequential versions", i, m.Version)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("migration %d_%s: missing up or down", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsBadNames(t *testing.T) {
+	bad := []fstest.MapFS{
+		{"x_init.up.sql": {Data: []byte("SELECT 1")}},
+		{"000001_init.sql": {Data: []byte("SELECT 1")}},
+		{"000001_init.down.sql": {Data: []byte("SELECT 1")}},
+	}
+	for _, fsys := range bad {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("loadMigrations(%v): want error", fsys)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package fakebot — локальный фейковый Bot API для тестов: записывает
+// исходящие вызовы, отдаёт подложенные апдейты через getUpdates и умеет
+// отвечать заданными ошибками (429 с retry_after, 403 и т.п.).
+package fakebot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const Token = "123456:TEST"
+
+// BotUser — пользователь, от имени которого работает фейковый бот
+var BotUser = tgbotapi.User{ID: 123456, IsBot: true, FirstName: "Test", UserName: "test_bot"}
+
+// Call — записанный вызов Bot API
+type Call struct {
+	Method string
+	Params map[string]string
+	Files  []string // имена полей с загруженными файлами
+}
+
+// ChatID — chat_id вызова (0, если его нет)
+func (c Call) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params["chat_id"], 10, 64)
+	return id
+}
+
+type failure struct {
+	code       int
+	desc       string
+	retryAfter int
+}
+
+type Server struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	calls     []Call
+	updates   []tgbotapi.Update
+	nextUpd   int
+	nextMsg   int
+	failures  map[string][]failure
+	members   map[[2]int64]string
+	changed   chan struct{} // закрывается и пересоздаётся при каждом новом вызове/апдейте
+	updatesCh chan struct{}
+}
+
+func New() *Server {
+	s := &Server{
+		nextUpd:   1,
+		nextMsg:   1,
+		failures:  make(map[string][]failure),
+		members:   make(map[[2]int64]string),
+		changed:   make(chan struct{}),
+		updatesCh: make(chan struct{}, 1),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *Server) Close() { s.srv.Close() }
+
+// Endpoint — шаблон для tgbotapi.NewBotAPIWithAPIEndpoint
+func (s *Server) Endpoint() string { return s.srv.URL + "/bot%s/%s" }
+
+// NewBot — клиент tgbotapi, настроенный на этот сервер
+func (s *Server) NewBot() (*tgbotapi.BotAPI, error) {
+	return tgbotapi.NewBotAPIWithAPIEndpoint(Token, s.Endpoint())
+}
+
+// FailNext — следующий вызов method завершится ошибкой Telegram
+func (s *Server) FailNext(method string, code int, description string, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], failure{code: code, desc: description, retryAfter: retryAfter})
+}
+
+// SetChatMember задаёт статус пользователя в чате для getChatMember (по умолчанию "left")
+func (s *Server) SetChatMember(chatID, userID int64, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[[2]int64{chatID, userID}] = status
+}
+
+// Calls — записанные вызовы; без аргументов — все, иначе только перечисленные методы
+func (s *Server) Calls(methods ...string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filter(methods)
+}
+
+// WaitCalls ждёт, пока наберётся n вызовов перечисленных методов
+func (s *Server) WaitCalls(n int, timeout time.Duration, methods ...string) ([]Call, bool) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		got := s.filter(methods)
+		changed := s.changed
+		s.mu.Unlock()
+		if len(got) >= n {
+			return got, true
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return got, false
+		}
+	}
+}
+
+func (s *Server) filter(methods []string) []Call {
+	var out []Call
+	for _, c := range s.calls {
+		if len(methods) == 0 {
+			out = append(out, c)
+			continue
+		}
+		for _, m := range methods {
+			if c.Method == m {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// InjectUpdate кладёт апдейт в очередь getUpdates (UpdateID проставляется, если 0)
+func (s *Server) InjectUpdate(upd tgbotapi.Update) tgbotapi.Update {
+	s.mu.Lock()
+	if upd.UpdateID == 0 {
+		upd.UpdateID = s.nextUpd
+	}
+	if upd.UpdateID >= s.nextUpd {
+		s.nextUpd = upd.UpdateID + 1
+	}
+	s.updates = append(s.updates, upd)
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	select {
+	case s.updatesCh <- struct{}{}:
+	default:
+	}
+	return upd
+}
+
+// SendText — пользователь пишет боту в личку; "/cmd" размечается как команда
+func (s *Server) SendText(user tgbotapi.User, text string) tgbotapi.Update {
+	m := &tgbotapi.Message{
+		MessageID: s.msgID(),
+		From:      &user,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: user.ID, Type: "private", UserName: user.UserName},
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		n := len(text)
+		if i := strings.IndexByte(text, ' '); i > 0 {
+			n = i
+		}
+		m.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: n}}
+	}
+	return s.InjectUpdate(tgbotapi.Update{Message: m})
+}
+
+// SendPhoto — пользователь присылает фото с уже известным file_id
+func (s *Server) SendPhoto(user tgbotapi.User, fileID string) tgbotapi.Update {
+	m := &tgbotapi.Message{
+		MessageID: s.msgID(),
+		From:      &user,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: user.ID, Type: "private", UserName: user.UserName},
+		Photo: []tgbotapi.PhotoSize{
+			{FileID: fileID + "-small", Width: 90, Height: 90},
+			{FileID: fileID, Width: 1280, Height: 1280},
+		},
+	}
+	return s.InjectUpdate(tgbotapi.Update{Message: m})
+}
+
+// PressButton — пользователь нажимает inline-кнопку с data в личке с ботом
+func (s *Server) PressButton(user tgbotapi.User, data string) tgbotapi.Update {
+	q := &tgbotapi.CallbackQuery{
+		ID:   strconv.Itoa(s.msgID()),
+		From: &user,
+		Message: &tgbotapi.Message{
+			MessageID: s.msgID(),
+			From:      &BotUser,
+			Date:      int(time.Now().Unix()),
+			Chat:      &tgbotapi.Chat{ID: user.ID, Type: "private"},
+		},
+		Data: data,
+	}
+	return s.InjectUpdate(tgbotapi.Update{CallbackQuery: q})
+}
+
+func (s *Server) msgID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextMsg
+	s.nextMsg++
+	return id
+}
+
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	// /bot<token>/<method>
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "bot"+Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", 0)
+		return
+	}
+	method := parts[1]
+
+	call := Call{Method: method, Params: map[string]string{}}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err == nil {
+			for k, v := range r.MultipartForm.Value {
+				call.Params[k] = v[0]
+			}
+			for k := range r.MultipartForm.File {
+				call.Files = append(call.Files, k)
+			}
+		}
+	} else if err := r.ParseForm(); err == nil {
+		for k, v := range r.PostForm {
+			call.Params[k] = v[0]
+		}
+	}
+
+	if method == "getUpdates" {
+		s.getUpdates(w, call)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.notifyLocked()
+	var fail *failure
+	if fs := s.failures[method]; len(fs) > 0 {
+		fail = &fs[0]
+		s.failures[method] = fs[1:]
+	}
+	s.mu.Unlock()
+
+	if fail != nil {
+		writeError(w, fail.code, fail.desc, fail.retryAfter)
+		return
+	}
+	writeOK(w, s.result(call))
+}
+
+func (s *Server) getUpdates(w http.ResponseWriter, call Call) {
+	offset, _ := strconv.Atoi(call.Params["offset"])
+	timeout, _ := strconv.Atoi(call.Params["timeout"])
+	wait := min(time.Duration(timeout)*time.Second, time.Second) // тестам долгий long-poll не нужен
+
+	deadline := time.After(wait)
+	for {
+		s.mu.Lock()
+		var out []tgbotapi.Update
+		for _, u := range s.updates {
+			if u.UpdateID >= offset {
+				out = append(out, u)
+			}
+		}
+		s.mu.Unlock()
+		if len(out) > 0 {
+			writeOK(w, out)
+			return
+		}
+		select {
+		case <-s.updatesCh:
+		case <-deadline:
+			writeOK(w, []tgbotapi.Update{})
+			return
+		}
+	}
+}
+
+func (s *Server) result(c Call) any {
+	switch c.Method {
+	case "getMe":
+		return BotUser
+	case "getChatMember":
+		chatID, _ := strconv.ParseInt(c.Params["chat_id"], 10, 64)
+		userID, _ := strconv.ParseInt(c.Params["user_id"], 10, 64)
+		s.mu.Lock()
+		status, ok := s.members[[2]int64{chatID, userID}]
+		s.mu.Unlock()
+		if !ok {
+			status = "left"
+		}
+		return tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: status}
+	case "sendMessage", "sendPhoto", "sendDice", "sendDocument", "copyMessage",
+		"editMessageText", "editMessageCaption", "editMessageReplyMarkup":
+		m := tgbotapi.Message{
+			MessageID: s.msgID(),
+			From:      &BotUser,
+			Date:      int(time.Now().Unix()),
+			Chat:      &tgbotapi.Chat{ID: c.ChatID()},
+			Text:      c.Params["text"],
+			Caption:   c.Params["caption"],
+		}
+		switch c.Method {
+		case "sendDice":
+			m.Dice = &tgbotapi.Dice{Emoji: c.Params["emoji"], Value: 6}
+		case "sendPhoto":
+			m.Photo = []tgbotapi.PhotoSize{{FileID: "photo-" + strconv.Itoa(m.MessageID), FileUniqueID: "u" + strconv.Itoa(m.MessageID)}}
+		}
+		return m
+	case "sendMediaGroup":
+		return []tgbotapi.Message{{MessageID: s.msgID(), Chat: &tgbotapi.Chat{ID: c.ChatID()}}}
+	}
+	return true // answerCallbackQuery, setMyCommands, deleteMessage, …
+}
+
+func writeOK(w http.ResponseWriter, result any) {
+	raw, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+func writeError(w http.ResponseWriter, code int, desc string, retryAfter int) {
+	resp := tgbotapi.APIResponse{Ok: false, ErrorCode: code, Description: desc}
+	if retryAfter > 0 {
+		resp.Parameters = &tgbotapi.ResponseParameters{RetryAfter: retryAfter}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Состояния диалога админа
+const (
+	stateAddWaitName      = "add_wait_name"
+	stateAddWaitValue     = "add_wait_value"
+	stateAddWaitImageURL  = "add_wait_image_url"
+	stateEditWaitName     = "edit_wait_name"
+	stateEditWaitValue    = "edit_wait_value"
+	stateEditWaitImageURL = "edit_wait_image_url"
+)
+
+func (h *Handler) cmdPromotions(ctx context.Context, upd tgbotapi.Update) error {
+	h.showPromotionsList(ctx, upd.Message.Chat.ID)
+	return nil
+}
+
+func (h *Handler) cmdAddPromotion(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: stateAddWaitName,
+	})
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Отправьте название новой скидки:"))
+	return nil
+}
+
+func (h *Handler) showPromotionsList(ctx context.Context, chatID int64) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	promotions, err := h.service.Repo.GetPromotions(dbctx)
+	if err != nil {
+		h.logger(ctx).Error("GetPromotions", "err", err)
+		return
+	}
+	if len(promotions) == 0 {
+		h.reply(ctx, tgbotapi.NewMessage(chatID, "Скидок не добавлено"))
+		return
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range promotions {
+		btn := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("[%d] %s", p.ID, p.Name), fmt.Sprintf("promotion_%d", p.ID))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn))
+	}
+	mk := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	msg := tgbotapi.NewMessage(chatID, "Выберите скидку")
+	msg.ReplyMarkup = mk
+	h.reply(ctx, msg)
+}
+
+func (h *Handler) cbPromotion(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "promotion_"))
+	mk := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Редактировать", fmt.Sprintf("edit_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить", fmt.Sprintf("del_%d", id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌐 Переводы", fmt.Sprintf("trans_%d", id)),
+		))
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Что сделать со скидкой?")
+	msg.ReplyMarkup = mk
+	h.reply(ctx, msg)
+	return nil
+}
+
+func (h *Handler) cbDelete(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id := strings.TrimPrefix(q.Data, "del_")
+	mk := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да, удалить", "delok_"+id),
+		))
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Точно удалить?")
+	msg.ReplyMarkup = mk
+	h.reply(ctx, msg)
+	return nil
+}
+
+func (h *Handler) cbDeleteConfirm(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "delok_"))
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := h.service.Repo.DeletePromotion(dbctx, id); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка удаления: "+err.Error()))
+	} else {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "✅ Удалено"))
+	}
+	return nil
+}
+
+func (h *Handler) cbEdit(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id := strings.TrimPrefix(q.Data, "edit_")
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: q.From.ID, State: stateEditWaitName, Data: id,
+	})
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Отправьте новое название:"))
+	return nil
+}
+
+func (h *Handler) stAddName(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: stateAddWaitValue, Data: m.Text,
+	})
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте значение скидки"))
+	return nil
+}
+
+func (h *Handler) stAddValue(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: stateAddWaitImageURL, Data: st.Data + "|" + m.Text,
+	})
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте ссылку на картинку"))
+	return nil
+}
+
+func (h *Handler) stAddImageURL(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	// Сохраняем image_url и создаем новую скидку
+	parts := strings.SplitN(st.Data, "|", 2)
+	name := parts[0]
+	url := parts[1]
+	imageURL := m.Text
+
+	// Создаем скидку в базе данных
+	if err := h.service.Repo.CreatePromotion(dbctx, name, url, imageURL); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+
+	// Очищаем состояние админа
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Скидка с картинкой добавлена"))
+	return nil
+}
+
+func (h *Handler) stEditName(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: stateEditWaitValue, Data: st.Data + "|" + m.Text,
+	})
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте новое значение скидки"))
+	return nil
+}
+
+func (h *Handler) stEditValue(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	// Обработка изменения URL скидки
+	parts := strings.SplitN(st.Data, "|", 2)
+	id, _ := strconv.Atoi(parts[0])
+	newName := parts[1]
+	newURL := m.Text
+
+	// Обработка нового image_url
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: stateEditWaitImageURL, Data: fmt.Sprintf("%d|%s|%s", id, newName, newURL),
+	})
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте ссылку на новую картинку"))
+	return nil
+}
+
+func (h *Handler) stEditImageURL(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	// Обновление image_url и данных скидки
+	parts := strings.SplitN(st.Data, "|", 3)
+	id, _ := strconv.Atoi(parts[0])
+	newName := parts[1]
+	newURL := parts[2]
+	newImageURL := m.Text
+
+	// Обновляем скидку в базе данных
+	if err := h.service.Repo.UpdatePromotion(dbctx, id, newName, newURL, newImageURL); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+
+	// Очищаем состояние админа
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Скидка обновлена с новой картинкой"))
+	return nil
+}
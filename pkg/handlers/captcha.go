@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// askCaptcha показывает задачу «не бот», если гость её ещё не прошёл.
+// asked — задача (или отказ после ошибок) отправлена, розыгрыш откладывается.
+// Кто уже участвовал, задачу не получает — ему сразу ответит клейм.
+func (h *Handler) askCaptcha(ctx context.Context, chatID, userID int64, kind string) (asked bool, err error) {
+	locale := i18n.FromContext(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if h.service.Repo.HasUserClaimed(dbctx, userID) {
+		return false, nil
+	}
+	c, ok, err := h.service.Challenge(dbctx, userID, kind, locale)
+	switch {
+	case errors.Is(err, services.ErrCaptchaLocked):
+		h.logger(ctx).Info("draw denied", "reason", "captcha_locked")
+		h.reply(ctx, tgbotapi.NewMessage(chatID, i18n.T(locale, "captcha.locked")))
+		return true, nil
+	case err != nil:
+		return true, fmt.Errorf("captcha: %w", err)
+	case !ok:
+		return false, nil
+	}
+	msg := tgbotapi.NewMessage(chatID, c.Question)
+	msg.ReplyMarkup = captchaKeyboard(c)
+	h.reply(ctx, msg)
+	return true, nil
+}
+
+func captchaKeyboard(c models.Captcha) tgbotapi.InlineKeyboardMarkup {
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(c.Options))
+	for _, o := range c.Options {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(o, "cap_"+c.Nonce+"_"+o))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// cbCaptcha — ответ на задачу: верный продолжает розыгрыш, неверный
+// заменяет задачу новой, пока не кончатся попытки
+func (h *Handler) cbCaptcha(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	chatID, msgID := q.Message.Chat.ID, q.Message.MessageID
+	st := h.settings.Get(ctx)
+	if st.Captcha == "" {
+		// проверку выключили, пока гость думал, — обычная попытка
+		return h.processDraw(ctx, chatID, q.From, false)
+	}
+
+	// cap_<nonce>_<ответ>
+	nonce, answer, _ := strings.Cut(strings.TrimPrefix(q.Data, "cap_"), "_")
+	locale := i18n.FromContext(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	next, passed, err := h.service.SolveCaptcha(dbctx, q.From.ID, nonce, answer, st.Captcha, locale)
+	switch {
+	case errors.Is(err, services.ErrCaptchaStale):
+		h.logger(ctx).Info("stale captcha button")
+		h.edit(ctx, tgbotapi.NewEditMessageText(chatID, msgID, i18n.T(locale, "captcha.stale")))
+		return nil
+	case errors.Is(err, services.ErrCaptchaLocked):
+		h.logger(ctx).Info("captcha failed", "attempts", next.Attempts)
+		h.edit(ctx, tgbotapi.NewEditMessageText(chatID, msgID, i18n.T(locale, "captcha.locked")))
+		return nil
+	case err != nil:
+		return fmt.Errorf("solve captcha: %w", err)
+	case passed:
+		h.edit(ctx, tgbotapi.NewEditMessageText(chatID, msgID, i18n.T(locale, "captcha.passed")))
+		return h.processDraw(ctx, chatID, q.From, true)
+	}
+
+	text := next.Question
+	if next.Attempts > 0 {
+		left := strconv.Itoa(services.CaptchaMaxAttempts - next.Attempts)
+		text = i18n.T(locale, "captcha.wrong", "left", left) + "\n\n" + text
+	}
+	h.edit(ctx, tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, text, captchaKeyboard(next)))
+	return nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	publicCommands = []string{"start", "draw", "language"}
+	adminCommands  = []string{"start", "promotions", "addpromotion", "user", "settings", "templates", "language"}
+)
+
+// botCommands — меню команд с описаниями на языке locale
+func botCommands(locale string, names []string) []tgbotapi.BotCommand {
+	cmds := make([]tgbotapi.BotCommand, 0, len(names))
+	for _, name := range names {
+		cmds = append(cmds, tgbotapi.BotCommand{Command: name, Description: i18n.T(locale, "command."+name)})
+	}
+	return cmds
+}
+
+// SetCommands публикует меню команд: для каждого поддерживаемого языка
+// (language_code) и без языка — на языке по умолчанию. Ошибки не фатальны.
+func (h *Handler) SetCommands(ctx context.Context) {
+	publicScope := tgbotapi.NewBotCommandScopeDefault()
+	adminScope := tgbotapi.NewBotCommandScopeChat(h.adminID)
+
+	set := func(scope *tgbotapi.BotCommandScope, names []string, locale, code string) {
+		cfg := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(*scope, code, botCommands(locale, names)...)
+		if _, err := h.sender.Request(ctx, cfg); err != nil {
+			h.log.Warn("set commands", "scope", scope.Type, "language", code, "err", err)
+		}
+	}
+	for _, loc := range i18n.Supported {
+		set(&publicScope, publicCommands, loc, loc)
+		set(&adminScope, adminCommands, loc, loc)
+	}
+	set(&publicScope, publicCommands, h.locale, "")
+	set(&adminScope, adminCommands, h.locale, "")
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// seenSet — короткоживущий in-memory кэш обработанных update_id перед походом в БД
+type seenSet struct {
+	mu        sync.Mutex
+	m         map[int64]time.Time
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+func newSeenSet(ttl time.Duration) *seenSet {
+	return &seenSet{m: make(map[int64]time.Time), ttl: ttl, lastSweep: time.Now()}
+}
+
+func (s *seenSet) Has(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.m[id]
+	return ok && time.Since(t) < s.ttl
+}
+
+func (s *seenSet) Add(id int64) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[id] = now
+	if now.Sub(s.lastSweep) > s.ttl {
+		for k, t := range s.m {
+			if now.Sub(t) > s.ttl {
+				delete(s.m, k)
+			}
+		}
+		s.lastSweep = now
+	}
+}
+
+// Dedup делает обработку идемпотентной по update_id: повторно доставленный
+// апдейт (рестарт, повтор очереди) пропускается. Отметка ставится только
+// после успешной обработки, чтобы ошибка оставалась повторяемой.
+func (h *Handler) Dedup() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			id := int64(upd.UpdateID)
+			if h.seen.Has(id) {
+				metrics.Inc("updates_duplicate_total")
+				h.logger(ctx).Debug("duplicate update skipped")
+				return nil
+			}
+
+			dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+			done, err := h.service.Repo.IsUpdateProcessed(dbctx, id)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("check processed update: %w", err)
+			}
+			if done {
+				h.seen.Add(id)
+				metrics.Inc("updates_duplicate_total")
+				h.logger(ctx).Debug("duplicate update skipped")
+				return nil
+			}
+
+			if err = next(ctx, upd); err != nil {
+				return err
+			}
+
+			h.seen.Add(id)
+			dbctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+			defer cancel()
+			if err = h.service.Repo.MarkUpdateProcessed(dbctx, id); err != nil {
+				// апдейт уже обработан — не повод для повтора
+				h.logger(ctx).Error("mark update processed", "err", err)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,572 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/config"
+	"github.com/Redarek/go-tg-bot-rest/pkg/fakebot"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/queue"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	"github.com/Redarek/go-tg-bot-rest/pkg/services"
+	"github.com/Redarek/go-tg-bot-rest/pkg/workers"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/time/rate"
+)
+
+const testAdminID = 1
+
+// startTestBot поднимает обработчик и outbox поверх фейкового Bot API и хранилища в памяти
+func startTestBot(t *testing.T) (*fakebot.Server, *repositories.Memory) {
+	t.Helper()
+	repo := repositories.NewMemory()
+	return startTestBotOn(t, repo, nil), repo
+}
+
+// startTestBotOn: store — хранилище обработчика; если задана updates, апдейты
+// идут через очередь с повторами, как в проде, иначе ошибка обработки валит тест
+func startTestBotOn(t *testing.T, store repositories.Store, updates repositories.UpdateQueue) *fakebot.Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv := fakebot.New()
+	t.Cleanup(srv.Close)
+	bot, err := srv.NewBot()
+	if err != nil {
+		t.Fatalf("new bot: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sender := services.NewSender(bot, rate.NewLimiter(rate.Inf, 1), 0.2, logger)
+	t.Cleanup(sender.Close)
+	cfg := &config.Config{AdminID: testAdminID, ShopURL: "https://example.com", HandlerTimeout: 5 * time.Second,
+		UserRateLimit: 1, UserRateBurst: 5}
+	h := NewHandler(sender, store, cfg, logger)
+	go services.NewOutbox(store, sender, logger).Run(ctx)
+
+	handle := func(upd tgbotapi.Update) {
+		if err := h.HandleUpdate(upd); err != nil {
+			t.Errorf("handle update %d: %v", upd.UpdateID, err)
+		}
+	}
+	if updates != nil {
+		q := queue.New(updates, h.HandleUpdate, h.UpdateFailed, cfg.HandlerTimeout, logger)
+		pool := workers.NewPool(2, 16, q.Process)
+		pool.Start()
+		runDone := make(chan struct{})
+		go func() {
+			defer close(runDone)
+			q.Run(ctx, pool)
+		}()
+		// как в main: сначала очередь перестаёт раздавать, затем пул дорабатывает
+		t.Cleanup(func() {
+			cancel()
+			<-runDone
+			_ = pool.Shutdown(context.Background())
+		})
+		handle = func(upd tgbotapi.Update) {
+			if err := q.Push(ctx, upd); err != nil {
+				t.Errorf("push update %d: %v", upd.UpdateID, err)
+			}
+		}
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 1
+	polled := bot.GetUpdatesChan(u)
+	t.Cleanup(bot.StopReceivingUpdates)
+	go func() {
+		for upd := range polled {
+			handle(upd)
+		}
+	}()
+	return srv
+}
+
+func TestStartDrawPrize(t *testing.T) {
+	srv, repo := startTestBot(t)
+	if err := repo.CreatePromotion(context.Background(), "ten", "-10%", ""); err != nil {
+		t.Fatalf("create promotion: %v", err)
+	}
+
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann", UserName: "ann"}
+
+	srv.SendText(user, "/start")
+	if _, ok := srv.WaitCalls(1, 5*time.Second, "sendPhoto"); !ok {
+		t.Fatal("start message was not sent")
+	}
+
+	srv.PressButton(user, "draw")
+	if _, ok := srv.WaitCalls(1, 5*time.Second, "sendDice"); !ok {
+		t.Fatal("dice was not sent")
+	}
+	msgs, ok := srv.WaitCalls(2, 10*time.Second, "sendMessage")
+	if !ok {
+		t.Fatalf("prize was not delivered, got %d messages", len(msgs))
+	}
+	if !strings.Contains(msgs[0].Params["text"], "-10%") {
+		t.Errorf("prize text = %q", msgs[0].Params["text"])
+	}
+	if n := len(srv.Calls("answerCallbackQuery")); n != 1 {
+		t.Errorf("answerCallbackQuery calls = %d, want 1", n)
+	}
+
+	// вторая попытка — отказ, нового приза нет
+	srv.PressButton(user, "draw")
+	msgs, ok = srv.WaitCalls(3, 5*time.Second, "sendMessage")
+	if !ok {
+		t.Fatal("already-claimed reply was not sent")
+	}
+	if !strings.Contains(msgs[2].Params["text"], "Попытка была одна") {
+		t.Errorf("reply text = %q", msgs[2].Params["text"])
+	}
+	if n := len(srv.Calls("sendDice")); n != 1 {
+		t.Errorf("sendDice calls = %d, want 1", n)
+	}
+}
+
+func TestAdminEditsTemplate(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "ten", "-10% & more", "")
+	_ = repo.SetSetting(ctx, "draw_delay", "0s")
+
+	admin := tgbotapi.User{ID: testAdminID, FirstName: "Admin"}
+	srv.SendText(admin, "/templates")
+	srv.PressButton(admin, "template_ru:prize")
+	if _, ok := srv.WaitCalls(2, 5*time.Second, "sendMessage"); !ok {
+		t.Fatal("template menu was not shown")
+	}
+
+	// шаблон с ошибкой не сохраняется
+	srv.SendText(admin, "{{.Nope}}")
+	msgs, ok := srv.WaitCalls(3, 5*time.Second, "sendMessage")
+	if !ok || !strings.Contains(msgs[2].Params["text"], "Ошибка в шаблоне") {
+		t.Fatalf("bad template was not rejected: %+v", msgs)
+	}
+
+	srv.SendText(admin, "🎁 {{.FirstName}}, твой приз: <b>{{.Value}}</b>")
+	msgs, ok = srv.WaitCalls(5, 5*time.Second, "sendMessage")
+	if !ok {
+		t.Fatal("template was not saved")
+	}
+	if preview := msgs[3].Params["text"]; !strings.Contains(preview, "Анна") {
+		t.Errorf("preview = %q, want sample data", preview)
+	}
+	if !strings.Contains(msgs[4].Params["text"], "Сохранено") {
+		t.Errorf("reply = %q", msgs[4].Params["text"])
+	}
+
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann<"}
+	srv.PressButton(user, "draw")
+	msgs, ok = srv.WaitCalls(6, 5*time.Second, "sendMessage")
+	if !ok {
+		for _, c := range srv.Calls() {
+			t.Logf("%s %v", c.Method, c.Params)
+		}
+		t.Fatal("prize was not delivered")
+	}
+	want := "🎁 Ann&lt;, твой приз: <b>-10% &amp; more</b>"
+	if got := msgs[5].Params["text"]; got != want {
+		t.Errorf("prize = %q, want %q", got, want)
+	}
+}
+
+func TestLocalizedDraw(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "Десятка", "-10%", "")
+	_ = repo.SetPromotionTranslation(ctx, 1, "en", models.PromotionText{Name: "Ten", Value: "10% off"})
+	_ = repo.SetSetting(ctx, "draw_delay", "0s")
+
+	user := tgbotapi.User{ID: 1001, FirstName: "John", LanguageCode: "en-GB"}
+	srv.SendText(user, "/start")
+	photos, ok := srv.WaitCalls(1, 5*time.Second, "sendPhoto")
+	if !ok {
+		t.Fatal("start message was not sent")
+	}
+	if caption := photos[0].Params["caption"]; !strings.Contains(caption, "Ready to try your luck") {
+		t.Errorf("caption = %q, want English", caption)
+	}
+	if markup := photos[0].Params["reply_markup"]; !strings.Contains(markup, "Get a discount") {
+		t.Errorf("reply_markup = %s, want English button", markup)
+	}
+
+	srv.PressButton(user, "draw")
+	msgs, ok := srv.WaitCalls(2, 10*time.Second, "sendMessage")
+	if !ok {
+		t.Fatal("prize was not delivered")
+	}
+	if text := msgs[0].Params["text"]; !strings.Contains(text, "10% off") || !strings.Contains(text, "Your lucky discount") {
+		t.Errorf("prize = %q, want the English translation", text)
+	}
+
+	// выбор в /language важнее языка Telegram
+	srv.PressButton(user, "lang_ru")
+	msgs, ok = srv.WaitCalls(3, 5*time.Second, "sendMessage")
+	if !ok || !strings.Contains(msgs[2].Params["text"], "Русский") {
+		t.Fatalf("language was not saved: %+v", msgs)
+	}
+	if lang, _ := repo.GetUserLanguage(ctx, user.ID); lang != "ru" {
+		t.Errorf("stored language = %q, want ru", lang)
+	}
+	srv.PressButton(user, "draw")
+	msgs, ok = srv.WaitCalls(4, 5*time.Second, "sendMessage")
+	if !ok || !strings.Contains(msgs[3].Params["text"], "Попытка была одна") {
+		t.Fatalf("already-claimed reply is not in Russian: %+v", msgs)
+	}
+}
+
+func TestStartScreen(t *testing.T) {
+	srv, _ := startTestBot(t)
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann"}
+
+	// встроенная картинка загружается один раз, дальше уходит по file_id
+	srv.SendText(user, "/start")
+	photos, ok := srv.WaitCalls(1, 5*time.Second, "sendPhoto")
+	if !ok || len(photos[0].Files) == 0 {
+		t.Fatalf("first /start did not upload the image: %+v", photos)
+	}
+	srv.SendText(user, "/start")
+	photos, ok = srv.WaitCalls(2, 5*time.Second, "sendPhoto")
+	if !ok || len(photos[1].Files) != 0 || !strings.HasPrefix(photos[1].Params["photo"], "photo-") {
+		t.Fatalf("second /start re-uploaded the image: %+v", photos[1])
+	}
+
+	// админ присылает фото и кнопки
+	admin := tgbotapi.User{ID: testAdminID, FirstName: "Admin"}
+	srv.PressButton(admin, "setting_start_image")
+	srv.SendPhoto(admin, "admin-photo")
+	srv.PressButton(admin, "setting_start_buttons")
+	srv.SendText(admin, "Меню | https://example.com/menu")
+	if _, ok = srv.WaitCalls(6, 5*time.Second, "sendMessage"); !ok {
+		t.Fatal("settings were not saved")
+	}
+
+	srv.SendText(user, "/start")
+	photos, ok = srv.WaitCalls(3, 5*time.Second, "sendPhoto")
+	if !ok {
+		t.Fatal("start message was not sent")
+	}
+	if got := photos[2].Params["photo"]; got != "admin-photo" {
+		t.Errorf("photo = %q, want the admin's file_id", got)
+	}
+	if markup := photos[2].Params["reply_markup"]; !strings.Contains(markup, "https://example.com/menu") {
+		t.Errorf("reply_markup = %s, want the link button", markup)
+	}
+
+	// Telegram не принял file_id — уходит встроенная картинка
+	srv.FailNext("sendPhoto", 400, "Bad Request: wrong file identifier", 0)
+	srv.SendText(user, "/start")
+	photos, ok = srv.WaitCalls(5, 5*time.Second, "sendPhoto")
+	if !ok || len(photos[4].Files) == 0 {
+		t.Fatalf("no fallback to the embedded image: %+v", photos)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann", LastName: "Bar", UserName: "ann", LanguageCode: "ru"}
+
+	srv.SendText(user, "/start from_flyer")
+	if _, ok := srv.WaitCalls(1, 5*time.Second, "sendPhoto"); !ok {
+		t.Fatal("start message was not sent")
+	}
+	u, err := repo.GetBotUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.BotUser{UserID: 1001, ChatID: 1001, Username: "ann", FirstName: "Ann", LastName: "Bar",
+		LanguageCode: "ru", StartPayload: "from_flyer"}
+	u.FirstSeen, u.LastSeen = time.Time{}, time.Time{}
+	if u != want {
+		t.Errorf("profile = %+v, want %+v", u, want)
+	}
+
+	// пользователь заблокировал бота
+	srv.InjectUpdate(tgbotapi.Update{MyChatMember: &tgbotapi.ChatMemberUpdated{
+		Chat:          tgbotapi.Chat{ID: user.ID, Type: "private"},
+		From:          user,
+		OldChatMember: tgbotapi.ChatMember{User: &fakebot.BotUser, Status: "member"},
+		NewChatMember: tgbotapi.ChatMember{User: &fakebot.BotUser, Status: "kicked"},
+	}})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if u, _ = repo.GetBotUser(ctx, user.ID); u.Blocked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("block was not recorded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitChatMessages ждёт n сообщений в чат chatID и возвращает их
+func waitChatMessages(t *testing.T, srv *fakebot.Server, chatID int64, n int) []fakebot.Call {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var got []fakebot.Call
+		for _, c := range srv.Calls("sendMessage") {
+			if c.ChatID() == chatID {
+				got = append(got, c)
+			}
+		}
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("chat %d: got %d messages, want %d", chatID, len(got), n)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAdminManagesUser(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "ten", "-10%", "")
+	_ = repo.SetSetting(ctx, "draw_delay", "0s")
+
+	admin := tgbotapi.User{ID: testAdminID, FirstName: "Admin"}
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann", UserName: "ann"}
+	srv.PressButton(user, "draw")
+	waitChatMessages(t, srv, user.ID, 2)
+
+	srv.SendText(admin, "/user @ann")
+	card := waitChatMessages(t, srv, admin.ID, 1)[0].Params["text"]
+	if !strings.Contains(card, "ten — -10%") || !strings.Contains(card, "id 1001") {
+		t.Errorf("card = %q", card)
+	}
+
+	// повтор приза приходит гостю ещё раз
+	srv.PressButton(admin, "usrresend_1001")
+	if msgs := waitChatMessages(t, srv, user.ID, 4); !strings.Contains(msgs[2].Params["text"], "-10%") {
+		t.Errorf("re-sent prize = %q", msgs[2].Params["text"])
+	}
+
+	srv.PressButton(admin, "usrban_1001")
+	srv.SendText(admin, "накрутка")
+	waitChatMessages(t, srv, admin.ID, 4)
+	if b, banned, _ := repo.GetBan(ctx, user.ID); !banned || b.Reason != "накрутка" || b.BannedBy != testAdminID {
+		t.Fatalf("ban = %+v, %v", b, banned)
+	}
+
+	srv.PressButton(admin, "usrreset_1001")
+	srv.PressButton(admin, "usrresetok_1001")
+	waitChatMessages(t, srv, admin.ID, 6)
+	if repo.HasUserClaimed(ctx, user.ID) {
+		t.Fatal("claim was not reset")
+	}
+
+	// забаненный гость не участвует даже после сброса
+	srv.PressButton(user, "draw")
+	msgs := waitChatMessages(t, srv, user.ID, 5)
+	if !strings.Contains(msgs[4].Params["text"], "недоступно") {
+		t.Errorf("reply = %q, want draw_denied", msgs[4].Params["text"])
+	}
+	if n := len(srv.Calls("sendDice")); n != 1 {
+		t.Errorf("sendDice calls = %d, want 1", n)
+	}
+}
+
+func TestDrawPolicy(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "ten", "-10%", "")
+	_ = repo.SetSetting(ctx, "require_username", "да")
+	_ = repo.SetSetting(ctx, "min_start_age", "1h")
+
+	anon := tgbotapi.User{ID: 1001, FirstName: "Anon"}
+	srv.PressButton(anon, "draw")
+	if msgs := waitChatMessages(t, srv, anon.ID, 1); !strings.Contains(msgs[0].Params["text"], "недоступно") {
+		t.Errorf("reply = %q, want draw_denied", msgs[0].Params["text"])
+	}
+
+	// только что пришёл — просим подождать
+	user := tgbotapi.User{ID: 1002, FirstName: "Ann", UserName: "ann"}
+	srv.PressButton(user, "draw")
+	if msgs := waitChatMessages(t, srv, user.ID, 1); !strings.Contains(msgs[0].Params["text"], "чуть позже") {
+		t.Errorf("reply = %q, want draw_wait", msgs[0].Params["text"])
+	}
+
+	if n := len(srv.Calls("sendDice")); n != 0 {
+		t.Errorf("sendDice calls = %d, want 0", n)
+	}
+}
+
+func TestDrawFlood(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "ten", "-10%", "")
+	_ = repo.SetSetting(ctx, "draw_delay", "0s")
+	_ = repo.SetSetting(ctx, "sub_channel_id", "-100")
+	_ = repo.SetSetting(ctx, "draw_flood", "2/1h")
+
+	// «Проверить подписку» попыток не тратит
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann"}
+	for i := 0; i < 3; i++ {
+		srv.PressButton(user, "draw")
+	}
+	if msgs := waitChatMessages(t, srv, user.ID, 3); !strings.Contains(msgs[2].Params["text"], "Подпишитесь") {
+		t.Fatalf("reply = %q, want subscribe_required", msgs[2].Params["text"])
+	}
+
+	// приз и отказ «уже участвовал», затем один ответ draw_wait и тишина
+	srv.SetChatMember(-100, user.ID, "member")
+	for i := 0; i < 4; i++ {
+		srv.PressButton(user, "draw")
+	}
+	if _, ok := srv.WaitCalls(7, 5*time.Second, "answerCallbackQuery"); !ok {
+		t.Fatal("callbacks were not answered")
+	}
+	// приз и CTA приходят из outbox, порядок с ответами не гарантирован
+	waitChatMessages(t, srv, user.ID, 7)
+	time.Sleep(200 * time.Millisecond)
+	msgs := waitChatMessages(t, srv, user.ID, 7)
+	var claimed, wait int
+	for _, m := range msgs {
+		switch text := m.Params["text"]; {
+		case strings.Contains(text, "Попытка была одна"):
+			claimed++
+		case strings.Contains(text, "чуть позже"):
+			wait++
+		}
+	}
+	if len(msgs) != 7 || claimed != 1 || wait != 1 {
+		t.Errorf("messages = %d (already claimed %d, draw_wait %d), want 7 with one of each", len(msgs), claimed, wait)
+	}
+	if n := len(srv.Calls("sendDice")); n != 1 {
+		t.Errorf("sendDice calls = %d, want 1", n)
+	}
+}
+
+func TestCaptchaBeforeDraw(t *testing.T) {
+	srv, repo := startTestBot(t)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "ten", "-10%", "")
+	_ = repo.SetSetting(ctx, "draw_delay", "0s")
+	_ = repo.SetSetting(ctx, "captcha", "math")
+	// попытка считается один раз — продолжение после задачи в лимит не упирается
+	_ = repo.SetSetting(ctx, "draw_flood", "1/1h")
+
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann"}
+	srv.PressButton(user, "draw")
+	msgs := waitChatMessages(t, srv, user.ID, 1)
+	if !strings.Contains(msgs[0].Params["text"], "не бот") || !strings.Contains(msgs[0].Params["reply_markup"], "cap_") {
+		t.Fatalf("captcha = %v", msgs[0].Params)
+	}
+	c, _, _ := repo.GetCaptcha(ctx, user.ID)
+
+	// неверный ответ — новая задача в том же сообщении, розыгрыша нет
+	wrong := "0"
+	srv.PressButton(user, "cap_"+c.Nonce+"_"+wrong)
+	edits, ok := srv.WaitCalls(1, 5*time.Second, "editMessageText")
+	if !ok || !strings.Contains(edits[0].Params["text"], "Осталось попыток: 2") {
+		t.Fatalf("wrong answer edit = %+v", edits)
+	}
+	if n := len(srv.Calls("sendDice")); n != 0 {
+		t.Fatalf("sendDice after a wrong answer = %d", n)
+	}
+
+	next, _, _ := repo.GetCaptcha(ctx, user.ID)
+	if next.Question == "" || next.Attempts != 1 {
+		t.Fatalf("captcha after a wrong answer = %+v (was %+v)", next, c)
+	}
+
+	// кнопка прежней задачи отклоняется и попытку не тратит
+	srv.PressButton(user, "cap_"+c.Nonce+"_"+c.Answer)
+	edits, ok = srv.WaitCalls(2, 5*time.Second, "editMessageText")
+	if !ok || !strings.Contains(edits[1].Params["text"], "устарела") {
+		t.Fatalf("stale answer edit = %+v", edits)
+	}
+	if cur, _, _ := repo.GetCaptcha(ctx, user.ID); cur.Attempts != 1 || cur.Nonce != next.Nonce {
+		t.Fatalf("captcha after a stale answer = %+v, want %+v", cur, next)
+	}
+
+	srv.PressButton(user, "cap_"+next.Nonce+"_"+next.Answer)
+	if _, ok = srv.WaitCalls(1, 5*time.Second, "sendDice"); !ok {
+		t.Fatal("draw did not continue after the right answer")
+	}
+	if msgs = waitChatMessages(t, srv, user.ID, 3); !strings.Contains(msgs[1].Params["text"], "-10%") {
+		t.Errorf("prize = %q", msgs[1].Params["text"])
+	}
+}
+
+// flakyClaims — первый клейм падает, как при сбое БД
+type flakyClaims struct {
+	*repositories.Memory
+	failed atomic.Bool
+}
+
+func (f *flakyClaims) ClaimPromotion(ctx context.Context, userID int64, once bool,
+	prize func(models.Promotion) []models.OutboxMessage) (models.Promotion, error) {
+	if f.failed.CompareAndSwap(false, true) {
+		return models.Promotion{}, errors.New("db down")
+	}
+	return f.Memory.ClaimPromotion(ctx, userID, once, prize)
+}
+
+// верный ответ уже сохранён, а розыгрыш упал — повтор апдейта из очереди
+// должен довести гостя до приза, а не показать «задача устарела»
+func TestCaptchaRetryAfterFailedDraw(t *testing.T) {
+	repo := repositories.NewMemory()
+	srv := startTestBotOn(t, &flakyClaims{Memory: repo}, repo)
+	ctx := context.Background()
+	_ = repo.CreatePromotion(ctx, "ten", "-10%", "")
+	_ = repo.SetSetting(ctx, "draw_delay", "0s")
+	_ = repo.SetSetting(ctx, "captcha", "math")
+
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann"}
+	srv.PressButton(user, "draw")
+	waitChatMessages(t, srv, user.ID, 1)
+	c, _, _ := repo.GetCaptcha(ctx, user.ID)
+
+	srv.PressButton(user, "cap_"+c.Nonce+"_"+c.Answer)
+	if _, ok := srv.WaitCalls(1, 10*time.Second, "sendDice"); !ok {
+		t.Fatal("draw was not retried after the failed claim")
+	}
+	if msgs := waitChatMessages(t, srv, user.ID, 2); !strings.Contains(msgs[1].Params["text"], "-10%") {
+		t.Errorf("prize = %q", msgs[1].Params["text"])
+	}
+	for _, e := range srv.Calls("editMessageText") {
+		if strings.Contains(e.Params["text"], "устарела") {
+			t.Errorf("retry was treated as a stale button: %q", e.Params["text"])
+		}
+	}
+	if dead := repo.DeadUpdates(); len(dead) != 0 {
+		t.Errorf("dead updates = %v", dead)
+	}
+}
+
+func TestReplyMarksBlockedUser(t *testing.T) {
+	srv, repo := startTestBot(t)
+	user := tgbotapi.User{ID: 1001, FirstName: "Ann"}
+
+	srv.FailNext("sendPhoto", 403, "Forbidden: bot was blocked by the user", 0)
+	srv.SendText(user, "/start")
+	if _, ok := srv.WaitCalls(1, 5*time.Second, "sendPhoto"); !ok {
+		t.Fatal("start message was not attempted")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		u, err := repo.GetBotUser(context.Background(), user.ID)
+		if err == nil && u.Blocked {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("user was not marked blocked: %+v, %v", u, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
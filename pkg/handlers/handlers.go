@@ -6,14 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Redarek/go-tg-bot-rest/pkg/config"
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/logging"
 	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/ratelimit"
 	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
 	"github.com/Redarek/go-tg-bot-rest/pkg/services"
+	"github.com/Redarek/go-tg-bot-rest/pkg/templates"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"log"
-	"strconv"
+	"golang.org/x/time/rate"
+	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,283 +27,234 @@ import (
 var StartJPG []byte
 
 type Handler struct {
-	bot            *tgbotapi.BotAPI
-	sender         *services.Sender
-	service        *services.Service
-	adminID        int64
-	shopURL        string
-	subChannelID   int64
-	subChannelLink string
+	sender   services.Messenger
+	service  *services.Service
+	settings *services.Settings
+	texts    *services.Templates
+	adminID  int64
+	log      *slog.Logger
+	timeout  time.Duration // на обработку одного апдейта
+	locale   string        // DEFAULT_LOCALE — язык, если язык пользователя не поддерживается
+
+	chain       UpdateFunc
+	panicNotify *rate.Limiter    // не чаще раза в минуту пишем админу о панике
+	userLimits  *ratelimit.Keyed // флуд-лимит апдейтов на пользователя
+	knownUsers  sync.Map         // user_id → seenProfile: что и когда этот процесс записал в bot_users
+	startFileID atomic.Value     // file_id встроенной картинки /start после первой загрузки
+	seen        *seenSet         // недавно обработанные update_id
 }
 
-func NewHandler(bot *tgbotapi.BotAPI, sender *services.Sender, db *pgxpool.Pool, cfg *config.Config) *Handler {
-	repo := repositories.NewRepository(db)
-	return &Handler{
-		bot:            bot,
-		sender:         sender,
-		service:        services.NewService(repo),
-		adminID:        cfg.AdminID,
-		shopURL:        cfg.ShopURL,
-		subChannelID:   cfg.SubChannelID,
-		subChannelLink: cfg.SubChannelLink,
+func NewHandler(sender services.Messenger, repo repositories.Store, cfg *config.Config, logger *slog.Logger) *Handler {
+	h := &Handler{
+		sender:      sender,
+		service:     services.NewService(repo),
+		settings:    services.NewSettings(repo, defaultSettings(cfg), logger),
+		texts:       services.NewTemplates(repo, logger),
+		adminID:     cfg.AdminID,
+		log:         logger,
+		locale:      i18n.Resolve("", "", cfg.DefaultLocale),
+		timeout:     cfg.HandlerTimeout,
+		panicNotify: rate.NewLimiter(rate.Every(time.Minute), 1),
+		seen:        newSeenSet(time.Hour),
+		userLimits: ratelimit.NewKeyed(10*time.Minute, func(int64) *rate.Limiter {
+			return rate.NewLimiter(rate.Limit(cfg.UserRateLimit), cfg.UserRateBurst)
+		}),
+	}
+
+	// Порядок важен: логгер апдейта нужен Recover, роль — лимитам и роутеру
+	h.chain = Chain(h.routes().Dispatch,
+		h.Logging(),
+		h.Recover(),
+		h.Dedup(),
+		h.Auth(),
+		h.AckCallback(),
+		h.RateLimit(),
+		h.Profile(),
+		h.Locale(),
+	)
+	return h
+}
+
+// defaultSettings — значения настроек, пока админ не изменил их в /settings
+func defaultSettings(cfg *config.Config) models.Settings {
+	return models.Settings{
+		ShopURL:        cfg.ShopURL,
+		SubChannelID:   cfg.SubChannelID,
+		SubChannelLink: cfg.SubChannelLink,
+		DrawDelay:      2 * time.Second,
 	}
 }
 
-func (h *Handler) HandleUpdate(upd tgbotapi.Update) {
+// logger — логгер текущего апдейта (update_id, user_id, chat_id, handler)
+func (h *Handler) logger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, h.log)
+}
+
+// HandleUpdate обрабатывает апдейт; ошибка означает сбой до видимого
+// пользователю результата — такой апдейт можно безопасно повторить
+func (h *Handler) HandleUpdate(upd tgbotapi.Update) error {
 	// базовый контекст на обработку одного апдейта
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
 	defer cancel()
 
-	switch {
-	case upd.Message != nil:
-		m := upd.Message
+	return h.chain(ctx, upd)
+}
 
-		// Сначала админские команды
-		if m.IsCommand() && m.From != nil && m.From.ID == h.adminID {
-			h.handleAdminCommand(ctx, m)
-			return
-		}
+// UpdateFailed — апдейт исчерпал повторы в очереди: пользователь, который
+// ждал ответа на кнопку или команду, получает сообщение об ошибке
+func (h *Handler) UpdateFailed(upd tgbotapi.Update) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h.sendError(logging.With(ctx, h.log, updateAttrs(upd)...), upd)
+}
 
-		// Пользовательские команды
-		if m.IsCommand() && m.From != nil && m.From.ID != h.adminID {
-			switch m.Command() {
-			case "draw":
-				h.processDraw(ctx, m.Chat.ID, m.From.ID)
-				return
-			case "start":
-				h.sendStartMessage(ctx, m.Chat.ID)
-				return
-			}
-		}
+// sendError — шаблон error в чат апдейта. Locale стоит глубже в цепочке,
+// а БД может быть недоступна — язык берём из Telegram.
+func (h *Handler) sendError(ctx context.Context, upd tgbotapi.Update) {
+	c := upd.FromChat()
+	if c == nil {
+		return
+	}
+	if u := upd.SentFrom(); u != nil {
+		ctx = i18n.WithLocale(ctx, i18n.Resolve("", u.LanguageCode, h.locale))
+	}
+	h.reply(ctx, h.textMessage(ctx, c.ID, "error", templates.Data{}))
+}
 
-		// Диалог админа — только для админа (чтобы не бить БД по каждому юзеру)
-		if m.From != nil && m.From.ID == h.adminID {
-			h.handleAdminDialog(ctx, m)
-		}
+func (h *Handler) cmdStart(ctx context.Context, upd tgbotapi.Update) error {
+	h.sendStartMessage(ctx, upd.Message.Chat.ID, upd.Message.From)
+	return nil
+}
 
-	case upd.CallbackQuery != nil:
-		h.handleCallback(ctx, upd.CallbackQuery)
-	}
+func (h *Handler) cmdDraw(ctx context.Context, upd tgbotapi.Update) error {
+	return h.processDraw(ctx, upd.Message.Chat.ID, upd.Message.From, false)
 }
 
-func (h *Handler) sendStartMessage(ctx context.Context, chatID int64) {
-	dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
-	defer cancel()
-	if err := h.service.Repo.UpsertBotUser(dbctx, chatID); err != nil {
-		log.Println("UpsertBotUser:", err)
-	}
+func (h *Handler) cbStart(ctx context.Context, upd tgbotapi.Update) error {
+	h.sendStartMessage(ctx, upd.CallbackQuery.Message.Chat.ID, upd.CallbackQuery.From)
+	return nil
+}
 
-	mk := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Получить скидку", "draw"),
-		))
+func (h *Handler) cbDraw(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	return h.processDraw(ctx, q.Message.Chat.ID, q.From, false)
+}
+
+// textData — данные для шаблонов сообщений
+func textData(st models.Settings, from *tgbotapi.User) templates.Data {
+	d := templates.Data{ShopURL: st.ShopURL, ChannelLink: st.SubChannelLink}
+	if from != nil {
+		d.FirstName = from.FirstName
+	}
+	return d
+}
 
-	caption := "🍀<b><u>Готов испытать удачу?</u></b>\n" +
-		"Запускай «Фортуну Вкуса» и забирай случайную скидку в нашем ресторане!\n" +
-		"😋<i>Получи скидку и приходи за своим вкусным бонусом!</i>"
+// textMessage — сообщение по шаблону name с HTML-разметкой
+func (h *Handler) textMessage(ctx context.Context, chatID int64, name string, data templates.Data) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(chatID, h.texts.Render(ctx, name, data))
+	msg.ParseMode = tgbotapi.ModeHTML
+	return msg
+}
 
-	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "start.jpg", Bytes: StartJPG})
-	photo.Caption = caption
-	photo.ReplyMarkup = mk
-	photo.ParseMode = tgbotapi.ModeHTML
-	if _, err := h.sender.Send(ctx, photo); err != nil {
-		log.Println("sendStartMessage:", err)
+// reply отправляет сообщение; ошибка пишется в лог, а 403 (бот заблокирован,
+// аккаунт удалён) отмечается в профиле гостя
+func (h *Handler) reply(ctx context.Context, msg tgbotapi.Chattable) {
+	if _, err := h.sender.Send(ctx, msg); err != nil {
+		h.sendFailed(ctx, err)
 	}
 }
 
-func (h *Handler) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
-	// всегда отвечаем на callback, чтобы убрать "часики"
-	if q.ID != "" {
-		_, _ = h.bot.Request(tgbotapi.NewCallback(q.ID, ""))
+// edit меняет отправленное ботом сообщение; ошибка пишется в лог
+func (h *Handler) edit(ctx context.Context, c tgbotapi.Chattable) {
+	if _, err := h.sender.EditMessage(ctx, c); err != nil {
+		h.logger(ctx).Error("edit message", "err", err)
 	}
+}
 
-	// Бывают инлайн-коллбэки без Message
-	if q.Message == nil {
+func (h *Handler) sendFailed(ctx context.Context, err error) {
+	var se *services.SendError
+	if errors.As(err, &se) && se.Code == http.StatusForbidden && se.ChatID > 0 {
+		h.logger(ctx).Warn("user unreachable", "err", err)
+		h.markBlocked(ctx, se.ChatID, true)
 		return
 	}
+	h.logger(ctx).Error("send message", "err", err)
+}
 
-	switch {
-	case q.Data == "start":
-		h.sendStartMessage(ctx, q.Message.Chat.ID)
-
-	case q.Data == "draw":
-		h.processDraw(ctx, q.Message.Chat.ID, q.From.ID)
-
-	case strings.HasPrefix(q.Data, "promotion_"):
-		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "promotion_"))
-		mk := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("✏️ Редактировать", fmt.Sprintf("edit_%d", id)),
-				tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить", fmt.Sprintf("del_%d", id)),
-			))
-		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Что сделать со скидкой?")
-		msg.ReplyMarkup = mk
-		if _, err := h.sender.Send(ctx, msg); err != nil {
-			log.Println(err)
-		}
-
-	case strings.HasPrefix(q.Data, "del_"):
-		id := strings.TrimPrefix(q.Data, "del_")
-		mk := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("✅ Да, удалить", "delok_"+id),
-			))
-		msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Точно удалить?")
-		msg.ReplyMarkup = mk
-		if _, err := h.sender.Send(ctx, msg); err != nil {
-			log.Println(err)
-		}
-
-	case strings.HasPrefix(q.Data, "delok_"):
-		id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "delok_"))
-		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-		defer cancel()
-		if err := h.service.Repo.DeletePromotion(dbctx, id); err != nil {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка удаления: "+err.Error()))
-		} else {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "✅ Удалено"))
-		}
-
-	case strings.HasPrefix(q.Data, "edit_"):
-		id := strings.TrimPrefix(q.Data, "edit_")
-		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-		defer cancel()
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: q.From.ID, State: "edit_wait_name", Data: id,
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Отправьте новое название:"))
+// shopKeyboard — кнопка со ссылкой на сайт; nil, если ссылка не настроена
+func (h *Handler) shopKeyboard(ctx context.Context, data templates.Data) *tgbotapi.InlineKeyboardMarkup {
+	if data.ShopURL == "" {
+		return nil
 	}
+	mk := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(h.texts.Render(ctx, "shop_button", data), data.ShopURL),
+		))
+	return &mk
 }
 
-func (h *Handler) handleAdminCommand(ctx context.Context, m *tgbotapi.Message) {
-	switch m.Command() {
-	case "start":
-		h.sendStartMessage(ctx, m.Chat.ID)
-	case "promotions":
-		h.showPromotionsList(ctx, m.Chat.ID)
-	case "addpromotion":
-		dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-		defer cancel()
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "add_wait_name",
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Отправьте название новой скидки:"))
-	case "draw":
-		h.processDraw(ctx, m.Chat.ID, m.From.ID)
+// startKeyboard — кнопка розыгрыша и кнопки-ссылки из настроек
+func (h *Handler) startKeyboard(ctx context.Context, st models.Settings, data templates.Data) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.texts.Render(ctx, "draw_button", data), "draw"),
+	)}
+	for _, b := range st.StartButtons {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(b.Text, b.URL)))
 	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
-func (h *Handler) showPromotionsList(ctx context.Context, chatID int64) {
-	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-	defer cancel()
-	promotions, err := h.service.Repo.GetPromotions(dbctx)
-	if err != nil {
-		log.Println("GetPromotions: ", err)
-		return
-	}
-	if len(promotions) == 0 {
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(chatID, "Скидок не добавлено"))
-		return
+// startPhoto — картинка /start: из настроек (file_id или URL), иначе
+// встроенная; встроенная загружается один раз, дальше — по file_id
+func (h *Handler) startPhoto(st models.Settings) tgbotapi.RequestFileData {
+	switch {
+	case strings.HasPrefix(st.StartImage, "http"):
+		return tgbotapi.FileURL(st.StartImage)
+	case st.StartImage != "":
+		return tgbotapi.FileID(st.StartImage)
 	}
-	var rows [][]tgbotapi.InlineKeyboardButton
-	for _, p := range promotions {
-		btn := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("[%d] %s", p.ID, p.Name), fmt.Sprintf("promotion_%d", p.ID))
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn))
+	if id, _ := h.startFileID.Load().(string); id != "" {
+		return tgbotapi.FileID(id)
 	}
-	mk := tgbotapi.NewInlineKeyboardMarkup(rows...)
-	msg := tgbotapi.NewMessage(chatID, "Выберите скидку")
-	msg.ReplyMarkup = mk
-	_, _ = h.sender.Send(ctx, msg)
+	return startFileBytes
 }
 
-func (h *Handler) handleAdminDialog(ctx context.Context, m *tgbotapi.Message) {
-	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-	defer cancel()
-	st, _ := h.service.Repo.GetAdminState(dbctx, m.From.ID)
-
-	switch st.State {
-
-	case "add_wait_name":
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "add_wait_value", Data: m.Text,
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте значение скидки"))
-
-	case "add_wait_value":
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "add_wait_image_url", Data: st.Data + "|" + m.Text,
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте ссылку на картинку"))
-
-	case "add_wait_image_url":
-		// Сохраняем image_url и создаем новую скидку
-		parts := strings.SplitN(st.Data, "|", 2)
-		name := parts[0]
-		url := parts[1]
-		imageURL := m.Text
-
-		// Создаем скидку в базе данных
-		if err := h.service.Repo.CreatePromotion(dbctx, name, url, imageURL); err != nil {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
-			return
-		}
+var startFileBytes = tgbotapi.FileBytes{Name: "start.jpg", Bytes: StartJPG}
 
-		// Очищаем состояние админа
-		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Скидка с картинкой добавлена"))
-
-	case "edit_wait_name":
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "edit_wait_value", Data: st.Data + "|" + m.Text,
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте новое значение скидки"))
-
-	case "edit_wait_value":
-		// Обработка изменения URL скидки
-		parts := strings.SplitN(st.Data, "|", 2)
-		id, _ := strconv.Atoi(parts[0])
-		newName := parts[1]
-		newURL := m.Text
-
-		// Обработка нового image_url
-		_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
-			UserID: m.From.ID, State: "edit_wait_image_url", Data: fmt.Sprintf("%d|%s|%s", id, newName, newURL),
-		})
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте ссылку на новую картинку"))
-	case "edit_wait_image_url":
-		// Обновление image_url и данных скидки
-		parts := strings.SplitN(st.Data, "|", 3)
-		id, _ := strconv.Atoi(parts[0])
-		newName := parts[1]
-		newURL := parts[2]
-		newImageURL := m.Text
-
-		// Обновляем скидку в базе данных
-		if err := h.service.Repo.UpdatePromotion(dbctx, id, newName, newURL, newImageURL); err != nil {
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
-			return
-		}
+func (h *Handler) sendStartMessage(ctx context.Context, chatID int64, from *tgbotapi.User) {
+	st := h.settings.Get(ctx)
+	data := textData(st, from)
 
-		// Очищаем состояние админа
-		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
-		_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Скидка обновлена с новой картинкой"))
+	photo := tgbotapi.NewPhoto(chatID, h.startPhoto(st))
+	_, embedded := photo.File.(tgbotapi.FileBytes)
+	photo.Caption = h.texts.Render(ctx, "start", data)
+	photo.ReplyMarkup = h.startKeyboard(ctx, st, data)
+	photo.ParseMode = tgbotapi.ModeHTML
+	msg, err := h.sender.Send(ctx, photo)
+	if err != nil && !embedded {
+		// file_id устарел или ссылка битая — пользователь всё равно получает экран
+		h.logger(ctx).Warn("send start image, falling back to embedded", "err", err)
+		h.startFileID.Store("")
+		photo.File, embedded = startFileBytes, true
+		msg, err = h.sender.Send(ctx, photo)
+	}
+	if err != nil {
+		h.sendFailed(ctx, err)
+		return
+	}
+	if embedded && len(msg.Photo) > 0 {
+		h.startFileID.Store(msg.Photo[len(msg.Photo)-1].FileID)
 	}
 }
 
-func (h *Handler) subscribed(ctx context.Context, userID int64) bool {
-	if h.subChannelID == 0 {
+func (h *Handler) subscribed(ctx context.Context, channelID, userID int64) bool {
+	if channelID == 0 {
 		return true
 	}
-	// Учитываем общий лимит Telegram
-	if err := h.sender.Wait(ctx); err != nil {
-		log.Println("rate wait:", err)
-		return false
-	}
-
-	cfg := tgbotapi.ChatConfigWithUser{ChatID: h.subChannelID, UserID: userID}
-	member, err := h.bot.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: cfg})
+	cfg := tgbotapi.ChatConfigWithUser{ChatID: channelID, UserID: userID}
+	member, err := h.sender.GetChatMember(ctx, tgbotapi.GetChatMemberConfig{ChatConfigWithUser: cfg})
 	if err != nil {
-		log.Println("GetChatMember:", err)
+		h.logger(ctx).Error("GetChatMember", "err", err)
 		return false
 	}
 	switch member.Status {
@@ -308,108 +265,138 @@ func (h *Handler) subscribed(ctx context.Context, userID int64) bool {
 	}
 }
 
-func (h *Handler) processDraw(ctx context.Context, chatID, userID int64) {
+// processDraw — попытка розыгрыша. captchaPassed — продолжение после верного
+// ответа на задачу «не бот»: попытку уже посчитали, задачу не спрашиваем.
+func (h *Handler) processDraw(ctx context.Context, chatID int64, from *tgbotapi.User, captchaPassed bool) error {
+	userID := from.ID
+	st := h.settings.Get(ctx)
+	data := textData(st, from)
+
+	// Анти-абуз: бан, эвристики аккаунта, пауза после /start
+	req := services.DrawRequest{UserID: userID, Username: from.UserName, IsPremium: from.IsPremium, Settings: st}
+	if userID != h.adminID {
+		dbctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		err := h.service.CheckDraw(dbctx, req)
+		if errors.Is(err, services.ErrDrawDenied) {
+			h.drawDenied(ctx, chatID, err, data)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("check draw: %w", err)
+		}
+	}
+
 	// Проверка подписки
 	subCtx, cancel := context.WithTimeout(ctx, 4*time.Second)
 	defer cancel()
-	if !h.subscribed(subCtx, userID) {
+	if !h.subscribed(subCtx, st.SubChannelID, userID) {
 		mk := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("Проверить подписку", "draw"),
+				tgbotapi.NewInlineKeyboardButtonData(i18n.T(i18n.FromContext(ctx), "button.check_subscription"), "draw"),
 			))
-		msg := tgbotapi.NewMessage(chatID, "Подпишитесь на канал "+h.subChannelLink+", чтобы получить скидку")
+		msg := h.textMessage(ctx, chatID, "subscribe_required", data)
 		msg.ReplyMarkup = mk
-		_, _ = h.sender.Send(ctx, msg)
-		return
+		h.reply(ctx, msg)
+		return nil
+	}
+
+	// Лимит попыток тратят только те, что дошли до клейма
+	if userID != h.adminID && !captchaPassed {
+		if err := h.service.CheckFlood(req); err != nil {
+			h.drawDenied(ctx, chatID, err, data)
+			return nil
+		}
 	}
 
-	// Клейм + выбор пакета
-	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	// Проверка «не бот» — перед клеймом
+	if st.Captcha != "" && userID != h.adminID && !captchaPassed {
+		if asked, err := h.askCaptcha(ctx, chatID, userID, st.Captcha); asked || err != nil {
+			return err
+		}
+	}
+
+	// Клейм + выбор пакета + приз в outbox — одной транзакцией
+	dbctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
-	p, err := h.service.ClaimPromotion(dbctx, userID, h.adminID)
+
+	p, err := h.service.ClaimPromotion(dbctx, userID, h.adminID, func(p models.Promotion) []models.OutboxMessage {
+		return h.prizeMessages(ctx, st.DrawDelay, data, chatID, userID, p)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, services.ErrAlreadyClaimed):
-			mk := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonURL("Забронировать столик", h.shopURL),
-				))
-			msg := tgbotapi.NewMessage(chatID,
-				"⚡️<u>Попытка была одна — и Фортуна уже подарила тебе особую скидку!</u>\n\n"+
-					"Забронируй столик на нашем сайте и воспользуйся скидкой в ресторане:\n"+
-					"🔹<a href=\"https://ketino.ru\">НАШ САЙТ</a>\n"+
-					"🔸<a href=\"https://instagram.com/ketino_rest\">INSTA</a>\n"+
-					"🔹<a href=\"https://vk.com/ketinorest\">VKONTAKTE</a>\n"+
-					"🔸<a href=\"https://t.me/ketinorest\">TELEGRAM</a>\n")
-			msg.ParseMode = tgbotapi.ModeHTML
-			msg.ReplyMarkup = mk
-			_, _ = h.sender.Send(ctx, msg)
-			return
+			msg := h.textMessage(ctx, chatID, "already_claimed", data)
+			if mk := h.shopKeyboard(ctx, data); mk != nil {
+				msg.ReplyMarkup = mk
+			}
+			h.reply(ctx, msg)
+			return nil
 		case errors.Is(err, repositories.ErrNoPromotions):
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(chatID, "⚠️ Скидок пока нет. Попробуйте позже."))
-			return
+			h.reply(ctx, h.textMessage(ctx, chatID, "no_promotions", data))
+			return nil
 		default:
-			log.Println("ClaimPromotion:", err)
-			_, _ = h.sender.Send(ctx, tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
-			return
+			// транзакция откатилась, ничего не отправлено — апдейт повторит очередь
+			return fmt.Errorf("claim promotion: %w", err)
 		}
 	}
+	h.logger(ctx).Info("promotion claimed", "promotion_id", p.ID)
 
-	// Отправляем "кубик" сразу…
+	// Отправляем "кубик" сразу, приз и CTA доставит outbox после анимации
 	dice := tgbotapi.NewDice(chatID)
 	dice.Emoji = "🎲"
-	_, err = h.sender.Send(ctx, dice)
-	if err != nil {
-		log.Println(err)
-	}
-
-	// …а дальше — без блокировки текущего воркера
-	go func(chatID int64, value, imageURL, shop string) {
-		goCtx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		time.Sleep(2 * time.Second)
-
-		text := "Ваша счастливая скидка:\n" +
-			"👉<u><b>" + value + "</b></u>"
+	h.reply(ctx, dice)
+	return nil
+}
 
-		if imageURL != "" {
-			// Отправляем фото
-			photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(imageURL))
-			photo.Caption = text
-			photo.ParseMode = tgbotapi.ModeHTML
-			_, err = h.sender.Send(goCtx, photo)
-			if err != nil {
-				log.Println("send with photo", err)
-			}
-		} else {
-			// Отправляем без фото
-			msg := tgbotapi.NewMessage(chatID, text)
-			msg.ParseMode = tgbotapi.ModeHTML
-			_, err = h.sender.Send(goCtx, msg)
-			if err != nil {
-				log.Println("send without photo", err)
-			}
-		}
+// drawDenied отвечает на отказ проверок; о флуде — один раз за окно draw_flood
+func (h *Handler) drawDenied(ctx context.Context, chatID int64, err error, data templates.Data) {
+	var d *services.DeniedError
+	errors.As(err, &d)
+	h.logger(ctx).Info("draw denied", "reason", d.Reason, "repeat", d.Repeat)
+	switch {
+	case d.Reason == services.DenyFlood && d.Repeat:
+		// уже ответили — на повторные нажатия молчим
+	case d.Reason == services.DenyFlood, d.Reason == services.DenyTooEarly:
+		h.reply(ctx, h.textMessage(ctx, chatID, "draw_wait", data))
+	default:
+		h.reply(ctx, h.textMessage(ctx, chatID, "draw_denied", data))
+	}
+}
 
-		time.Sleep(1 * time.Second)
+// prizeMessages — сообщения с призом и CTA, которые outbox отправит через
+// delay (после анимации кубика) и секундой позже. Ключи claim:<user_id>:…
+// уникальны для каждого клейма — по ним /user показывает доставку.
+func (h *Handler) prizeMessages(ctx context.Context, delay time.Duration, data templates.Data,
+	chatID, userID int64, p models.Promotion) []models.OutboxMessage {
+	now := time.Now()
+	key := fmt.Sprintf("claim:%d:%d", userID, now.UnixNano())
+	t := p.Localized(i18n.FromContext(ctx))
+	data.Promotion, data.Value = t.Name, t.Value
+
+	prize := models.OutboxMessage{
+		DedupKey:  key + ":prize",
+		ChatID:    chatID,
+		Kind:      services.OutboxText,
+		Text:      h.texts.Render(ctx, "prize", data),
+		ParseMode: tgbotapi.ModeHTML,
+		SendAfter: now.Add(delay),
+	}
+	if p.ImageURL != "" {
+		prize.Kind = services.OutboxPhoto
+		prize.ImageURL = p.ImageURL
+	}
 
-		mk := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonURL("Забронировать столик", shop),
-			))
-		after := "Забронируй столик на нашем сайте и воспользуйся скидкой в ресторане:\n" +
-			"🔹<a href=\"https://ketino.ru\">НАШ САЙТ</a>\n" +
-			"🔸<a href=\"https://instagram.com/ketino_rest\">INSTA</a>\n" +
-			"🔹<a href=\"https://vk.com/ketinorest\">VKONTAKTE</a>\n" +
-			"🔸<a href=\"https://t.me/ketinorest\">TELEGRAM</a>\n"
-
-		am := tgbotapi.NewMessage(chatID, after)
-		am.ParseMode = tgbotapi.ModeHTML
-		am.ReplyMarkup = mk
-		_, err = h.sender.Send(goCtx, am)
-		if err != nil {
-			log.Println("send CAT", err)
-		}
-	}(chatID, p.Value, p.ImageURL, h.shopURL)
+	cta := models.OutboxMessage{
+		DedupKey:  key + ":cta",
+		ChatID:    chatID,
+		Kind:      services.OutboxText,
+		Text:      h.texts.Render(ctx, "prize_cta", data),
+		ParseMode: tgbotapi.ModeHTML,
+		SendAfter: now.Add(delay + time.Second),
+	}
+	if mk := h.shopKeyboard(ctx, data); mk != nil {
+		cta.ReplyMarkup = services.OutboxMarkup(*mk)
+	}
+	return []models.OutboxMessage{prize, cta}
 }
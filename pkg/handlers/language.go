@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// langAuto — сбросить выбор и снова брать язык из Telegram
+const langAuto = "auto"
+
+// Locale кладёт в контекст язык собеседника: выбранный в /language,
+// иначе language_code из Telegram, иначе DEFAULT_LOCALE. Выбор берётся из
+// профиля, который держит Profile; в БД идём, только если его там нет.
+func (h *Handler) Locale() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			return next(i18n.WithLocale(ctx, h.userLocale(ctx, upd)), upd)
+		}
+	}
+}
+
+func (h *Handler) userLocale(ctx context.Context, upd tgbotapi.Update) string {
+	u := upd.SentFrom()
+	if u == nil {
+		return h.locale
+	}
+	var chosen string
+	if c := upd.FromChat(); c != nil && c.IsPrivate() {
+		chosen = h.chosenLanguage(ctx, u.ID)
+	}
+	return i18n.Resolve(chosen, u.LanguageCode, h.locale)
+}
+
+func (h *Handler) chosenLanguage(ctx context.Context, userID int64) string {
+	if v, ok := h.knownUsers.Load(userID); ok {
+		return v.(seenProfile).language
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	chosen, err := h.service.Repo.GetUserLanguage(dbctx, userID)
+	if err != nil {
+		h.logger(ctx).Warn("GetUserLanguage", "err", err)
+	}
+	return chosen
+}
+
+func (h *Handler) cmdLanguage(ctx context.Context, upd tgbotapi.Update) error {
+	locale := i18n.FromContext(ctx)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, loc := range i18n.Supported {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.Name(loc), "lang_"+loc)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(locale, "language.auto"), "lang_"+langAuto)))
+
+	msg := tgbotapi.NewMessage(upd.Message.Chat.ID, i18n.T(locale, "language.choose"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.reply(ctx, msg)
+	return nil
+}
+
+func (h *Handler) cbLanguage(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	choice := strings.TrimPrefix(q.Data, "lang_")
+	if choice == langAuto {
+		choice = ""
+	} else if _, ok := i18n.Match(choice); !ok {
+		return nil
+	}
+
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := h.service.Repo.SetUserLanguage(dbctx, q.From.ID, choice); err != nil {
+		return err
+	}
+	if v, ok := h.knownUsers.Load(q.From.ID); ok {
+		p := v.(seenProfile)
+		p.language = choice
+		h.knownUsers.Store(q.From.ID, p)
+	}
+	locale := i18n.Resolve(choice, q.From.LanguageCode, h.locale)
+	h.logger(ctx).Info("language changed", "locale", locale, "auto", choice == "")
+
+	text := i18n.T(locale, "language.saved", "lang", i18n.Name(locale))
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, text))
+	return nil
+}
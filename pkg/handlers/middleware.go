@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/logging"
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// UpdateFunc — обработчик одного апдейта
+type UpdateFunc func(ctx context.Context, upd tgbotapi.Update) error
+
+// Middleware оборачивает обработчик (логирование, восстановление после паник, …)
+type Middleware func(next UpdateFunc) UpdateFunc
+
+// Chain применяет middleware так, что первый в списке выполняется первым
+func Chain(h UpdateFunc, mws ...Middleware) UpdateFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Recover ловит панику обработчика: лог со стеком, метрика,
+// уведомление админу (с лимитом) и нейтральный ответ пользователю.
+// Паника не повторяется очередью — пользователь уже получил ответ
+func (h *Handler) Recover() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				metrics.Inc("handler_panics_total")
+				stack := debug.Stack()
+				h.logger(ctx).Error("handler panic", "panic", fmt.Sprint(r), "stack", string(stack))
+
+				// контекст апдейта мог уже истечь — отвечаем в свежем
+				replyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+
+				h.sendError(replyCtx, upd)
+				if h.adminID != 0 && h.panicNotify.Allow() {
+					text := fmt.Sprintf("⚠️ Паника при обработке апдейта %d: %v", upd.UpdateID, r)
+					h.reply(replyCtx, tgbotapi.NewMessage(h.adminID, text))
+				}
+			}()
+			return next(ctx, upd)
+		}
+	}
+}
+
+// updateAttrs — атрибуты корреляции для всех записей по апдейту
+func updateAttrs(upd tgbotapi.Update) []any {
+	attrs := []any{"update_id", upd.UpdateID}
+	if u := upd.SentFrom(); u != nil {
+		attrs = append(attrs, "user_id", u.ID)
+	}
+	if c := upd.FromChat(); c != nil {
+		attrs = append(attrs, "chat_id", c.ID)
+	}
+	return attrs
+}
+
+// Logging кладёт в контекст логгер апдейта и пишет время обработки
+func (h *Handler) Logging() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			ctx = logging.With(ctx, h.log, updateAttrs(upd)...)
+			start := time.Now()
+			h.logger(ctx).Debug("update received")
+			err := next(ctx, upd)
+			if err != nil {
+				h.logger(ctx).Warn("update failed", "duration", time.Since(start), "err", err)
+				return err
+			}
+			h.logger(ctx).Debug("update handled", "duration", time.Since(start))
+			return nil
+		}
+	}
+}
+
+// Auth определяет роль отправителя
+func (h *Handler) Auth() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			role := RoleUser
+			if u := upd.SentFrom(); u != nil && u.ID == h.adminID {
+				role = RoleAdmin
+			}
+			return next(withRole(ctx, role), upd)
+		}
+	}
+}
+
+// AckCallback всегда отвечает на callback, чтобы убрать "часики"
+func (h *Handler) AckCallback() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			if q := upd.CallbackQuery; q != nil && q.ID != "" {
+				if err := h.sender.AnswerCallback(ctx, q.ID, ""); err != nil {
+					h.logger(ctx).Warn("answer callback", "err", err)
+				}
+			}
+			return next(ctx, upd)
+		}
+	}
+}
+
+// RateLimit отбрасывает флуд от одного пользователя (админа не ограничиваем)
+func (h *Handler) RateLimit() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			if u := upd.SentFrom(); u != nil && RoleFrom(ctx) < RoleAdmin && !h.userLimits.Allow(u.ID) {
+				metrics.Inc("updates_rate_limited_total")
+				h.logger(ctx).Info("update dropped by per-user rate limit")
+				return nil
+			}
+			return next(ctx, upd)
+		}
+	}
+}
+
+// Как часто неизменившийся профиль обновляется в bot_users (last_seen)
+const profileTouchEvery = time.Minute
+
+type seenProfile struct {
+	user     models.BotUser // без отметок времени — для сравнения
+	language string         // выбор из /language — его читает Locale
+	at       time.Time
+}
+
+// Profile ведёт профиль собеседника в bot_users: username, имя, язык,
+// last_seen и параметр первого /start. Изменившийся профиль пишется сразу,
+// неизменившийся — не чаще profileTouchEvery. my_chat_member отмечает,
+// что пользователь заблокировал бота или вернулся.
+func (h *Handler) Profile() Middleware {
+	return func(next UpdateFunc) UpdateFunc {
+		return func(ctx context.Context, upd tgbotapi.Update) error {
+			if mcm := upd.MyChatMember; mcm != nil && mcm.Chat.IsPrivate() {
+				h.markBlocked(ctx, mcm.From.ID, mcm.NewChatMember.Status == "kicked")
+				return next(ctx, upd)
+			}
+			u, c := upd.SentFrom(), upd.FromChat()
+			if u == nil || c == nil || !c.IsPrivate() {
+				return next(ctx, upd)
+			}
+
+			p := models.BotUser{
+				UserID: u.ID, ChatID: c.ID, Username: u.UserName,
+				FirstName: u.FirstName, LastName: u.LastName, LanguageCode: u.LanguageCode,
+			}
+			if m := upd.Message; m != nil && m.IsCommand() && m.Command() == "start" {
+				p.StartPayload = m.CommandArguments()
+			}
+			if v, ok := h.knownUsers.Load(u.ID); ok {
+				prev := v.(seenProfile)
+				if prev.user == p && time.Since(prev.at) < profileTouchEvery {
+					return next(ctx, upd)
+				}
+			}
+
+			dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+			if language, err := h.service.Repo.UpsertBotUser(dbctx, p); err != nil {
+				h.logger(ctx).Error("UpsertBotUser", "err", err)
+			} else {
+				p.StartPayload = "" // параметр пишется только первый — дальше не повод для записи
+				h.knownUsers.Store(u.ID, seenProfile{user: p, language: language, at: time.Now()})
+			}
+			cancel()
+			return next(ctx, upd)
+		}
+	}
+}
+
+func (h *Handler) markBlocked(ctx context.Context, userID int64, blocked bool) {
+	dbctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if err := h.service.Repo.SetUserBlocked(dbctx, userID, blocked); err != nil {
+		h.logger(ctx).Error("SetUserBlocked", "err", err)
+		return
+	}
+	// следующее сообщение пользователя снова запишет профиль
+	h.knownUsers.Delete(userID)
+	h.logger(ctx).Info("bot block status changed", "blocked", blocked)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/config"
+	"github.com/Redarek/go-tg-bot-rest/pkg/fakebot"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	"github.com/Redarek/go-tg-bot-rest/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/time/rate"
+)
+
+// newTestHandler — обработчик без поллинга: middleware вызываются напрямую
+func newTestHandler(t *testing.T, store repositories.Store) (*Handler, *fakebot.Server) {
+	t.Helper()
+	srv := fakebot.New()
+	t.Cleanup(srv.Close)
+	bot, err := srv.NewBot()
+	if err != nil {
+		t.Fatalf("new bot: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sender := services.NewSender(bot, rate.NewLimiter(rate.Inf, 1), 0.2, logger)
+	t.Cleanup(sender.Close)
+	cfg := &config.Config{AdminID: testAdminID, HandlerTimeout: 5 * time.Second,
+		UserRateLimit: 1, UserRateBurst: 5}
+	return NewHandler(sender, store, cfg, logger), srv
+}
+
+// markFails — MarkUpdateProcessed недоступен
+type markFails struct{ *repositories.Memory }
+
+func (markFails) MarkUpdateProcessed(context.Context, int64) error { return errors.New("db down") }
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	upd := tgbotapi.Update{UpdateID: 10}
+
+	t.Run("duplicate", func(t *testing.T) {
+		repo := repositories.NewMemory()
+		h, _ := newTestHandler(t, repo)
+		calls := 0
+		next := h.Dedup()(func(context.Context, tgbotapi.Update) error { calls++; return nil })
+
+		for i := 0; i < 2; i++ {
+			if err := next(ctx, upd); err != nil {
+				t.Fatalf("delivery %d: %v", i+1, err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("handler ran %d times, want 1", calls)
+		}
+		if done, _ := repo.IsUpdateProcessed(ctx, 10); !done {
+			t.Error("update is not marked processed")
+		}
+
+		// после рестарта seen пуст — дубль отсекает отметка в БД
+		h2, _ := newTestHandler(t, repo)
+		if err := h2.Dedup()(func(context.Context, tgbotapi.Update) error { calls++; return nil })(ctx, upd); err != nil || calls != 1 {
+			t.Errorf("redelivery after restart: err = %v, handler calls = %d, want 1", err, calls)
+		}
+	})
+
+	// ошибка обработки не отмечает апдейт — повтор очереди его выполнит
+	t.Run("handler error", func(t *testing.T) {
+		repo := repositories.NewMemory()
+		h, _ := newTestHandler(t, repo)
+		calls := 0
+		next := h.Dedup()(func(context.Context, tgbotapi.Update) error {
+			calls++
+			if calls == 1 {
+				return errors.New("db down")
+			}
+			return nil
+		})
+
+		if err := next(ctx, upd); err == nil {
+			t.Fatal("handler error was swallowed")
+		}
+		if done, _ := repo.IsUpdateProcessed(ctx, 10); done {
+			t.Fatal("failed update is marked processed")
+		}
+		if err := next(ctx, upd); err != nil || calls != 2 {
+			t.Fatalf("retry: err = %v, handler calls = %d, want 2", err, calls)
+		}
+		if done, _ := repo.IsUpdateProcessed(ctx, 10); !done {
+			t.Error("update is not marked processed after a successful retry")
+		}
+	})
+
+	// отметка не записалась — апдейт всё равно обработан, дубль отсекает seen
+	t.Run("mark fails", func(t *testing.T) {
+		h, _ := newTestHandler(t, markFails{repositories.NewMemory()})
+		calls := 0
+		next := h.Dedup()(func(context.Context, tgbotapi.Update) error { calls++; return nil })
+
+		for i := 0; i < 2; i++ {
+			if err := next(ctx, upd); err != nil {
+				t.Fatalf("delivery %d: %v", i+1, err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("handler ran %d times, want 1", calls)
+		}
+	})
+}
+
+func TestRecover(t *testing.T) {
+	h, srv := newTestHandler(t, repositories.NewMemory())
+	next := h.Recover()(func(context.Context, tgbotapi.Update) error { panic("boom") })
+
+	user := &tgbotapi.User{ID: 42, LanguageCode: "ru"}
+	for id := 1; id <= 2; id++ {
+		upd := tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{
+			From: user, Chat: &tgbotapi.Chat{ID: user.ID, Type: "private"}, Text: "/start"}}
+		if err := next(context.Background(), upd); err != nil {
+			t.Fatalf("update %d: recovered panic returned %v, want nil", id, err)
+		}
+	}
+
+	var toUser, toAdmin []fakebot.Call
+	for _, c := range srv.Calls("sendMessage") {
+		switch c.ChatID() {
+		case user.ID:
+			toUser = append(toUser, c)
+		case testAdminID:
+			toAdmin = append(toAdmin, c)
+		}
+	}
+	if len(toUser) != 2 {
+		t.Errorf("user got %d error replies, want 2", len(toUser))
+	}
+	// уведомления админу ограничены panicNotify: второе за минуту не уходит
+	if len(toAdmin) != 1 || !strings.Contains(toAdmin[0].Params["text"], "boom") {
+		t.Errorf("admin notifications = %+v, want one about the panic", toAdmin)
+	}
+}
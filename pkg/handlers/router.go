@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/logging"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Role — минимальная роль, нужная для маршрута
+type Role int
+
+const (
+	RoleUser Role = iota
+	RoleAdmin
+)
+
+type roleKey struct{}
+type adminStateKey struct{}
+
+func withRole(ctx context.Context, r Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, r)
+}
+
+// RoleFrom — роль отправителя апдейта (выставляет middleware Auth)
+func RoleFrom(ctx context.Context) Role {
+	r, _ := ctx.Value(roleKey{}).(Role)
+	return r
+}
+
+// adminStateFrom — состояние диалога, по которому роутер выбрал обработчик
+func adminStateFrom(ctx context.Context) models.AdminState {
+	st, _ := ctx.Value(adminStateKey{}).(models.AdminState)
+	return st
+}
+
+// StateLoader достаёт текущее состояние диалога пользователя
+type StateLoader func(ctx context.Context, userID int64) (models.AdminState, error)
+
+type route struct {
+	name   string
+	role   Role
+	handle UpdateFunc
+}
+
+type prefixRoute struct {
+	prefix string
+	route
+}
+
+// Router сопоставляет апдейт с обработчиком: команды, callback-данные
+// (точные и по префиксу) и состояния диалога
+type Router struct {
+	commands  map[string]route
+	callbacks map[string]route
+	prefixes  []prefixRoute
+	states    map[string]route
+	loadState StateLoader
+	stateRole Role // минимальная роль среди состояний — чтобы не ходить в БД за каждым юзером
+}
+
+func NewRouter(loadState StateLoader) *Router {
+	return &Router{
+		commands:  make(map[string]route),
+		callbacks: make(map[string]route),
+		states:    make(map[string]route),
+		loadState: loadState,
+		stateRole: RoleAdmin,
+	}
+}
+
+func (r *Router) Command(name string, role Role, fn UpdateFunc) {
+	r.commands[name] = route{name: "command:" + name, role: role, handle: fn}
+}
+
+// Callback — точное совпадение callback data
+func (r *Router) Callback(data string, role Role, fn UpdateFunc) {
+	r.callbacks[data] = route{name: "callback:" + data, role: role, handle: fn}
+}
+
+// CallbackPrefix — callback data вида prefix+payload; побеждает самый длинный префикс
+func (r *Router) CallbackPrefix(prefix string, role Role, fn UpdateFunc) {
+	r.prefixes = append(r.prefixes, prefixRoute{prefix: prefix, route: route{name: "callback:" + prefix, role: role, handle: fn}})
+	sort.SliceStable(r.prefixes, func(i, j int) bool { return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix) })
+}
+
+// State — обработчик текстового сообщения в состоянии диалога
+func (r *Router) State(state string, role Role, fn UpdateFunc) {
+	r.states[state] = route{name: "state:" + state, role: role, handle: fn}
+	if role < r.stateRole {
+		r.stateRole = role
+	}
+}
+
+func (r *Router) Dispatch(ctx context.Context, upd tgbotapi.Update) error {
+	ctx, rt, err := r.match(ctx, upd)
+	if err != nil {
+		return err
+	}
+	if rt.handle == nil {
+		logging.FromContext(ctx, nil).Debug("no route for update")
+		return nil
+	}
+	if RoleFrom(ctx) < rt.role {
+		logging.FromContext(ctx, nil).Debug("route forbidden", "route", rt.name)
+		return nil
+	}
+	return rt.handle(logging.With(ctx, nil, "handler", rt.name), upd)
+}
+
+// match возвращает контекст, дополненный состоянием диалога, если маршрут выбран по нему;
+// пустой route — обработчика нет
+func (r *Router) match(ctx context.Context, upd tgbotapi.Update) (context.Context, route, error) {
+	switch {
+	case upd.Message != nil:
+		m := upd.Message
+		if m.IsCommand() {
+			return ctx, r.commands[m.Command()], nil
+		}
+		if m.From == nil || len(r.states) == 0 || RoleFrom(ctx) < r.stateRole {
+			return ctx, route{}, nil
+		}
+		st, err := r.loadState(ctx, m.From.ID)
+		if err != nil {
+			return ctx, route{}, fmt.Errorf("load dialog state: %w", err)
+		}
+		return context.WithValue(ctx, adminStateKey{}, st), r.states[st.State], nil
+
+	case upd.CallbackQuery != nil:
+		// Бывают инлайн-коллбэки без Message
+		if upd.CallbackQuery.Message == nil {
+			return ctx, route{}, nil
+		}
+		data := upd.CallbackQuery.Data
+		if rt, ok := r.callbacks[data]; ok {
+			return ctx, rt, nil
+		}
+		for _, p := range r.prefixes {
+			if strings.HasPrefix(data, p.prefix) {
+				return ctx, p.route, nil
+			}
+		}
+	}
+	return ctx, route{}, nil
+}
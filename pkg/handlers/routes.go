@@ -0,0 +1,57 @@
+package handlers
+
+// routes — единственное место, где регистрируются команды, callback-и и
+// состояния диалога; новая фича добавляется здесь, а не в центральном switch
+func (h *Handler) routes() *Router {
+	r := NewRouter(h.service.Repo.GetAdminState)
+
+	// Пользователь (админ тоже может)
+	r.Command("start", RoleUser, h.cmdStart)
+	r.Command("draw", RoleUser, h.cmdDraw)
+	r.Callback("start", RoleUser, h.cbStart)
+	r.Callback("draw", RoleUser, h.cbDraw)
+	r.Command("language", RoleUser, h.cmdLanguage)
+	r.CallbackPrefix("lang_", RoleUser, h.cbLanguage)
+	r.CallbackPrefix("cap_", RoleUser, h.cbCaptcha)
+
+	// Управление скидками
+	r.Command("promotions", RoleAdmin, h.cmdPromotions)
+	r.Command("addpromotion", RoleAdmin, h.cmdAddPromotion)
+	r.CallbackPrefix("promotion_", RoleAdmin, h.cbPromotion)
+	r.CallbackPrefix("del_", RoleAdmin, h.cbDelete)
+	r.CallbackPrefix("delok_", RoleAdmin, h.cbDeleteConfirm)
+	r.CallbackPrefix("edit_", RoleAdmin, h.cbEdit)
+	r.CallbackPrefix("trans_", RoleAdmin, h.cbTranslations)
+	r.CallbackPrefix("transl_", RoleAdmin, h.cbTranslation)
+
+	r.State(stateAddWaitName, RoleAdmin, h.stAddName)
+	r.State(stateAddWaitValue, RoleAdmin, h.stAddValue)
+	r.State(stateAddWaitImageURL, RoleAdmin, h.stAddImageURL)
+	r.State(stateEditWaitName, RoleAdmin, h.stEditName)
+	r.State(stateEditWaitValue, RoleAdmin, h.stEditValue)
+	r.State(stateEditWaitImageURL, RoleAdmin, h.stEditImageURL)
+	r.State(stateTransWaitName, RoleAdmin, h.stTransName)
+	r.State(stateTransWaitValue, RoleAdmin, h.stTransValue)
+
+	// Гости: карточка, сброс участия, бан, повтор приза
+	r.Command("user", RoleAdmin, h.cmdUser)
+	r.CallbackPrefix("usrreset_", RoleAdmin, h.cbUserReset)
+	r.CallbackPrefix("usrresetok_", RoleAdmin, h.cbUserResetConfirm)
+	r.CallbackPrefix("usrban_", RoleAdmin, h.cbUserBan)
+	r.CallbackPrefix("usrunban_", RoleAdmin, h.cbUserUnban)
+	r.CallbackPrefix("usrresend_", RoleAdmin, h.cbUserResend)
+	r.State(stateBanWaitReason, RoleAdmin, h.stBanReason)
+
+	// Настройки времени выполнения
+	r.Command("settings", RoleAdmin, h.cmdSettings)
+	r.CallbackPrefix("setting_", RoleAdmin, h.cbSetting)
+	r.State(stateSettingWaitValue, RoleAdmin, h.stSettingValue)
+
+	// Тексты сообщений
+	r.Command("templates", RoleAdmin, h.cmdTemplates)
+	r.CallbackPrefix("template_", RoleAdmin, h.cbTemplate)
+	r.CallbackPrefix("tpllang_", RoleAdmin, h.cbTemplatesLocale)
+	r.State(stateTemplateWaitBody, RoleAdmin, h.stTemplateBody)
+
+	return r
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const stateSettingWaitValue = "setting_wait_value"
+
+// resetValue — ответ админа, возвращающий настройке значение по умолчанию
+const resetValue = "-"
+
+func (h *Handler) cmdSettings(ctx context.Context, upd tgbotapi.Update) error {
+	h.showSettings(ctx, upd.Message.Chat.ID)
+	return nil
+}
+
+func (h *Handler) showSettings(ctx context.Context, chatID int64) {
+	st := h.settings.Get(ctx)
+
+	var b strings.Builder
+	b.WriteString("⚙️ Настройки (применяются сразу, без перезапуска):\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, d := range services.SettingDefs {
+		b.WriteString("• " + d.Title + ": " + shorten(d.Value(st), 60) + "\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(d.Title, "setting_"+d.Key)))
+	}
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.reply(ctx, msg)
+}
+
+func (h *Handler) cbSetting(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	d, ok := services.SettingDefByKey(strings.TrimPrefix(q.Data, "setting_"))
+	if !ok {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: q.From.ID, State: stateSettingWaitValue, Data: d.Key,
+	})
+	text := d.Title + "\nСейчас: " + d.Value(h.settings.Get(ctx)) +
+		"\n\nОтправьте новое значение (" + d.Hint + ") или «" + resetValue + "», чтобы вернуть значение по умолчанию."
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, text))
+	return nil
+}
+
+func (h *Handler) stSettingValue(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	key := adminStateFrom(ctx).Data
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	value := m.Text
+	if d, _ := services.SettingDefByKey(key); d.Photo && len(m.Photo) > 0 {
+		// самый большой размер; file_id переживает рестарт и не требует повторной загрузки
+		value = m.Photo[len(m.Photo)-1].FileID
+	}
+
+	var err error
+	if strings.TrimSpace(value) == resetValue {
+		err = h.settings.Reset(dbctx, key)
+	} else {
+		err = h.settings.Set(dbctx, key, value)
+	}
+	if err != nil {
+		// состояние не сбрасываем — админ может сразу прислать исправленное значение
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	h.logger(ctx).Info("setting changed", "key", key)
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Сохранено"))
+	h.showSettings(ctx, m.Chat.ID)
+	return nil
+}
+
+// shorten обрезает строку до n символов для списков
+func shorten(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
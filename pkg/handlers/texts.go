@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/templates"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const stateTemplateWaitBody = "template_wait_body"
+
+func (h *Handler) cmdTemplates(ctx context.Context, upd tgbotapi.Update) error {
+	h.showTemplates(ctx, upd.Message.Chat.ID, h.locale)
+	return nil
+}
+
+// cbTemplatesLocale — переключение языка в меню /templates
+func (h *Handler) cbTemplatesLocale(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	if loc, ok := i18n.Match(strings.TrimPrefix(q.Data, "tpllang_")); ok {
+		h.showTemplates(ctx, q.Message.Chat.ID, loc)
+	}
+	return nil
+}
+
+func (h *Handler) showTemplates(ctx context.Context, chatID int64, locale string) {
+	var langs []tgbotapi.InlineKeyboardButton
+	for _, loc := range i18n.Supported {
+		title := i18n.Name(loc)
+		if loc == locale {
+			title = "• " + title
+		}
+		langs = append(langs, tgbotapi.NewInlineKeyboardButtonData(title, "tpllang_"+loc))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{langs}
+	for _, d := range templates.Defs {
+		title := d.Title
+		if h.texts.Customized(ctx, locale, d.Name) {
+			title = "✏️ " + title
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(title, "template_"+locale+":"+d.Name)))
+	}
+	msg := tgbotapi.NewMessage(chatID, "📝 Тексты сообщений, "+i18n.Name(locale)+" (✏️ — изменён):")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.reply(ctx, msg)
+}
+
+// templateRef разбирает «locale:name» из callback-а и состояния диалога;
+// без языка (состояние, начатое до мультиязычности) — русский шаблон
+func templateRef(s string) (string, templates.Def, bool) {
+	loc, name, found := strings.Cut(s, ":")
+	if !found {
+		loc, name = i18n.Fallback, s
+	}
+	if _, ok := i18n.Match(loc); !ok {
+		return "", templates.Def{}, false
+	}
+	d, ok := templates.Lookup(name)
+	return loc, d, ok
+}
+
+func (h *Handler) cbTemplate(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	ref := strings.TrimPrefix(q.Data, "template_")
+	locale, d, ok := templateRef(ref)
+	if !ok {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: q.From.ID, State: stateTemplateWaitBody, Data: ref,
+	})
+
+	var b strings.Builder
+	b.WriteString(d.Title + ", " + i18n.Name(locale) + "\n\nСейчас:\n" + h.texts.Source(ctx, locale, d.Name) + "\n\n")
+	if len(d.Vars) > 0 {
+		b.WriteString("Подстановки: {{." + strings.Join(d.Vars, "}}, {{.") + "}}\n")
+	}
+	if d.HTML {
+		b.WriteString("Разметка: <b>, <i>, <u>, <a href=\"…\">.\n")
+	}
+	b.WriteString("\nОтправьте новый текст или «" + resetValue + "», чтобы вернуть встроенный.")
+	// без ParseMode — админ видит разметку как есть
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, b.String()))
+	return nil
+}
+
+func (h *Handler) stTemplateBody(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	locale, d, ok := templateRef(adminStateFrom(ctx).Data)
+	if !ok {
+		return h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	}
+	name := d.Name
+
+	if strings.TrimSpace(m.Text) == resetValue {
+		if err := h.texts.Reset(dbctx, locale, name); err != nil {
+			h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			return nil
+		}
+		return h.templateSaved(ctx, dbctx, m, locale, name)
+	}
+
+	preview, err := h.texts.Check(name, m.Text)
+	if err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка в шаблоне: "+err.Error()))
+		return nil
+	}
+	// Превью на тестовых данных заодно проверяет, что Telegram принимает разметку
+	msg := tgbotapi.NewMessage(m.Chat.ID, preview)
+	if d.HTML {
+		msg.ParseMode = tgbotapi.ModeHTML
+	}
+	if _, err = h.sender.Send(ctx, msg); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Telegram не принял текст: "+err.Error()))
+		return nil
+	}
+	if err = h.texts.Set(dbctx, locale, name, m.Text); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+	return h.templateSaved(ctx, dbctx, m, locale, name)
+}
+
+func (h *Handler) templateSaved(ctx, dbctx context.Context, m *tgbotapi.Message, locale, name string) error {
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	h.logger(ctx).Info("template changed", "name", name, "locale", locale)
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Сохранено"))
+	return nil
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Перевод скидки: название, затем значение; Data — «id:locale[|name]»
+const (
+	stateTransWaitName  = "trans_wait_name"
+	stateTransWaitValue = "trans_wait_value"
+)
+
+func (h *Handler) findPromotion(ctx context.Context, id int) (models.Promotion, bool) {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	list, err := h.service.Repo.GetPromotions(dbctx)
+	if err != nil {
+		h.logger(ctx).Error("GetPromotions", "err", err)
+		return models.Promotion{}, false
+	}
+	for _, p := range list {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return models.Promotion{}, false
+}
+
+func (h *Handler) cbTranslations(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, _ := strconv.Atoi(strings.TrimPrefix(q.Data, "trans_"))
+	p, ok := h.findPromotion(ctx, id)
+	if !ok {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Скидка не найдена"))
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🌐 %s — %s\nБез перевода пользователь видит этот текст.\n", p.Name, p.Value)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, loc := range i18n.Supported {
+		title := i18n.Name(loc)
+		if t, ok := p.Texts[loc]; ok {
+			fmt.Fprintf(&b, "\n%s: %s — %s", i18n.Name(loc), t.Name, t.Value)
+			title = "✏️ " + title
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("transl_%d:%s", id, loc))))
+	}
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.reply(ctx, msg)
+	return nil
+}
+
+func (h *Handler) cbTranslation(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	ref := strings.TrimPrefix(q.Data, "transl_")
+	_, loc, ok := translationRef(ref)
+	if !ok {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: q.From.ID, State: stateTransWaitName, Data: ref,
+	})
+	text := fmt.Sprintf("Отправьте название скидки, %s, или «%s», чтобы убрать перевод:", i18n.Name(loc), resetValue)
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, text))
+	return nil
+}
+
+// translationRef разбирает «id:locale»
+func translationRef(s string) (int, string, bool) {
+	idStr, loc, _ := strings.Cut(s, ":")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, "", false
+	}
+	loc, ok := i18n.Match(loc)
+	return id, loc, ok
+}
+
+func (h *Handler) stTransName(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	id, loc, ok := translationRef(st.Data)
+	if !ok {
+		return h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	}
+
+	if strings.TrimSpace(m.Text) == resetValue {
+		if err := h.service.Repo.DeletePromotionTranslation(dbctx, id, loc); err != nil {
+			h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+			return nil
+		}
+		_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Перевод удалён"))
+		return nil
+	}
+
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: m.From.ID, State: stateTransWaitValue, Data: st.Data + "|" + m.Text,
+	})
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Теперь отправьте значение скидки, "+i18n.Name(loc)))
+	return nil
+}
+
+func (h *Handler) stTransValue(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	st := adminStateFrom(ctx)
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	ref, name, _ := strings.Cut(st.Data, "|")
+	id, loc, ok := translationRef(ref)
+	if !ok {
+		return h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	}
+
+	t := models.PromotionText{Name: name, Value: m.Text}
+	if err := h.service.Repo.SetPromotionTranslation(dbctx, id, loc, t); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	h.logger(ctx).Info("promotion translation changed", "promotion_id", id, "locale", loc)
+	h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "✅ Перевод сохранён"))
+	return nil
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Бан: админ присылает причину; Data — user_id
+const stateBanWaitReason = "ban_wait_reason"
+
+const timeLayout = "02.01.2006 15:04"
+
+// cmdUser — карточка гостя: /user 123456789 или /user @username
+func (h *Handler) cmdUser(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	arg := strings.TrimSpace(m.CommandArguments())
+	if arg == "" {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Использование: /user <id> или /user @username"))
+		return nil
+	}
+
+	dbctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	var (
+		u   models.BotUser
+		err error
+	)
+	if id, perr := strconv.ParseInt(arg, 10, 64); perr == nil {
+		u, err = h.service.Repo.GetBotUser(dbctx, id)
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			// профиля может не быть (гость до учёта профилей), а клейм — быть
+			u, err = models.BotUser{UserID: id, ChatID: id}, nil
+		}
+	} else {
+		u, err = h.service.Repo.FindBotUser(dbctx, strings.TrimPrefix(arg, "@"))
+	}
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Пользователь не найден"))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("find user: %w", err)
+	}
+	return h.showUser(ctx, m.Chat.ID, u)
+}
+
+func (h *Handler) showUser(ctx context.Context, chatID int64, u models.BotUser) error {
+	dbctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	claim, claimed, err := h.service.Repo.GetClaim(dbctx, u.UserID)
+	if err != nil {
+		return fmt.Errorf("get claim: %w", err)
+	}
+	ban, banned, err := h.service.Repo.GetBan(dbctx, u.UserID)
+	if err != nil {
+		return fmt.Errorf("get ban: %w", err)
+	}
+	deliveries, err := h.service.Repo.OutboxByKeyPrefix(dbctx, fmt.Sprintf("claim:%d:", u.UserID))
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+
+	var b strings.Builder
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = "Без имени"
+	}
+	fmt.Fprintf(&b, "👤 %s", name)
+	if u.Username != "" {
+		fmt.Fprintf(&b, " (@%s)", u.Username)
+	}
+	fmt.Fprintf(&b, ", id %d\n", u.UserID)
+	if u.FirstSeen.IsZero() {
+		b.WriteString("Профиля нет — гость не писал боту после обновления\n")
+	} else {
+		fmt.Fprintf(&b, "Язык: %s", i18n.Resolve(u.Language, u.LanguageCode, h.locale))
+		if u.LanguageCode != "" {
+			fmt.Fprintf(&b, " (Telegram: %s)", u.LanguageCode)
+		}
+		fmt.Fprintf(&b, "\nПервый визит: %s, последний: %s\n",
+			u.FirstSeen.Format(timeLayout), u.LastSeen.Format(timeLayout))
+		if u.StartPayload != "" {
+			fmt.Fprintf(&b, "Пришёл по ссылке: %s\n", u.StartPayload)
+		}
+		if u.Blocked {
+			b.WriteString("⛔ Заблокировал бота\n")
+		}
+	}
+	if banned {
+		fmt.Fprintf(&b, "🚫 Забанен %s", ban.CreatedAt.Format(timeLayout))
+		if ban.Reason != "" {
+			fmt.Fprintf(&b, ": %s", ban.Reason)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	switch {
+	case !claimed:
+		b.WriteString("🎲 Не участвовал\n")
+	case claim.Promotion == "":
+		b.WriteString("🎁 Участвовал (скидка не записана — клейм старше истории)\n")
+	default:
+		fmt.Fprintf(&b, "🎁 %s — %s", claim.Promotion, claim.Value)
+		if !claim.ClaimedAt.IsZero() {
+			fmt.Fprintf(&b, " (%s)", claim.ClaimedAt.Format(timeLayout))
+		}
+		b.WriteString("\n")
+	}
+	for _, d := range deliveries {
+		fmt.Fprintf(&b, "📨 %s %s: %s", d.SendAfter.Format(timeLayout), deliveryPart(d.DedupKey), deliveryStatus(d.Status))
+		if d.LastError != "" && d.Status != "sent" {
+			fmt.Fprintf(&b, " (%d попыт., %s)", d.Attempts, shorten(d.LastError, 80))
+		}
+		b.WriteString("\n")
+	}
+
+	id := strconv.FormatInt(u.UserID, 10)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if claimed {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("♻️ Сбросить участие", "usrreset_"+id)))
+		if claim.Promotion != "" {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📨 Отправить приз ещё раз", "usrresend_"+id)))
+		}
+	}
+	if banned {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Разбанить", "usrunban_"+id)))
+	} else if u.UserID != h.adminID {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Забанить", "usrban_"+id)))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	h.reply(ctx, msg)
+	return nil
+}
+
+func deliveryPart(key string) string {
+	switch {
+	case strings.HasSuffix(key, ":prize"):
+		return "приз"
+	case strings.HasSuffix(key, ":cta"):
+		return "после приза"
+	}
+	return key
+}
+
+func deliveryStatus(status string) string {
+	switch status {
+	case "sent":
+		return "доставлено"
+	case "failed":
+		return "не доставлено"
+	}
+	return "ожидает отправки"
+}
+
+// userIDFrom — user_id из callback data вида prefix+id
+func userIDFrom(data, prefix string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(data, prefix), 10, 64)
+	return id, err == nil
+}
+
+// reshowUser — карточка после действия; гостя без профиля показываем по id
+func (h *Handler) reshowUser(ctx context.Context, chatID, userID int64) error {
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	u, err := h.service.Repo.GetBotUser(dbctx, userID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		u, err = models.BotUser{UserID: userID, ChatID: userID}, nil
+	}
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	return h.showUser(ctx, chatID, u)
+}
+
+func (h *Handler) cbUserReset(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, ok := userIDFrom(q.Data, "usrreset_")
+	if !ok {
+		return nil
+	}
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Сбросить участие? Гость сможет снова крутить розыгрыш.")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Да, сбросить", fmt.Sprintf("usrresetok_%d", id))))
+	h.reply(ctx, msg)
+	return nil
+}
+
+func (h *Handler) cbUserResetConfirm(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, ok := userIDFrom(q.Data, "usrresetok_")
+	if !ok {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	reset, err := h.service.Repo.ResetClaim(dbctx, id)
+	if err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+	if reset {
+		h.logger(ctx).Info("claim reset by admin", "target_user_id", id)
+	}
+	return h.reshowUser(ctx, q.Message.Chat.ID, id)
+}
+
+func (h *Handler) cbUserBan(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, ok := userIDFrom(q.Data, "usrban_")
+	if !ok || id == h.adminID {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_ = h.service.Repo.SetAdminState(dbctx, models.AdminState{
+		UserID: q.From.ID, State: stateBanWaitReason, Data: strconv.FormatInt(id, 10),
+	})
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID,
+		"Отправьте причину бана или «"+resetValue+"», чтобы забанить без причины:"))
+	return nil
+}
+
+func (h *Handler) stBanReason(ctx context.Context, upd tgbotapi.Update) error {
+	m := upd.Message
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	id, err := strconv.ParseInt(adminStateFrom(ctx).Data, 10, 64)
+	if err != nil {
+		return h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	}
+	reason := strings.TrimSpace(m.Text)
+	if reason == resetValue {
+		reason = ""
+	}
+	if err = h.service.Repo.BanUser(dbctx, models.Ban{UserID: id, Reason: reason, BannedBy: m.From.ID}); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(m.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+	_ = h.service.Repo.ClearAdminState(dbctx, m.From.ID)
+	h.logger(ctx).Info("user banned", "target_user_id", id)
+	return h.reshowUser(ctx, m.Chat.ID, id)
+}
+
+func (h *Handler) cbUserUnban(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, ok := userIDFrom(q.Data, "usrunban_")
+	if !ok {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := h.service.Repo.UnbanUser(dbctx, id); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+	h.logger(ctx).Info("user unbanned", "target_user_id", id)
+	return h.reshowUser(ctx, q.Message.Chat.ID, id)
+}
+
+// cbUserResend ставит приз гостя в outbox повторно — на его языке, без паузы
+func (h *Handler) cbUserResend(ctx context.Context, upd tgbotapi.Update) error {
+	q := upd.CallbackQuery
+	id, ok := userIDFrom(q.Data, "usrresend_")
+	if !ok {
+		return nil
+	}
+	dbctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	claim, claimed, err := h.service.Repo.GetClaim(dbctx, id)
+	if err != nil {
+		return fmt.Errorf("get claim: %w", err)
+	}
+	if !claimed || claim.Promotion == "" {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Нечего отправлять: скидка не записана"))
+		return nil
+	}
+	u, err := h.service.Repo.GetBotUser(dbctx, id)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		u, err = models.BotUser{UserID: id, ChatID: id}, nil
+	}
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	// текущая версия скидки с переводами; если её удалили — снимок из клейма
+	p := models.Promotion{ID: claim.PromotionID, Name: claim.Promotion, Value: claim.Value}
+	if found, ok := h.findPromotion(ctx, claim.PromotionID); ok {
+		p = found
+	}
+	userCtx := i18n.WithLocale(ctx, i18n.Resolve(u.Language, u.LanguageCode, h.locale))
+	st := h.settings.Get(ctx)
+	data := textData(st, &tgbotapi.User{FirstName: u.FirstName})
+	msgs := h.prizeMessages(userCtx, 0, data, u.ChatID, id, p)
+	if err = h.service.Repo.EnqueueOutbox(dbctx, msgs...); err != nil {
+		h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "Ошибка: "+err.Error()))
+		return nil
+	}
+	h.logger(ctx).Info("prize re-sent by admin", "target_user_id", id, "promotion_id", p.ID)
+	h.reply(ctx, tgbotapi.NewMessage(q.Message.Chat.ID, "✅ Приз поставлен в очередь отправки"))
+	return nil
+}
@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check — одна проверка готовности (БД, Telegram, пул воркеров …)
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name     string
+	fn       Check
+	optional bool // сбой виден в отчёте, но не снимает готовность
+}
+
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Add(name string, fn Check) { c.add(namedCheck{name: name, fn: fn}) }
+
+// AddOptional — проверка внешней зависимости, без которой процесс всё равно
+// работает (Telegram): её сбой не делает /readyz неготовым, иначе авария
+// у Telegram перезапускала бы исправные контейнеры
+func (c *Checker) AddOptional(name string, fn Check) {
+	c.add(namedCheck{name: name, fn: fn, optional: true})
+}
+
+func (c *Checker) add(ch namedCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, ch)
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Ready прогоняет все проверки параллельно и возвращает статус по каждой
+func (c *Checker) Ready(ctx context.Context) (map[string]string, bool) {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		ok  = true
+		res = make(map[string]string, len(checks))
+	)
+	for _, ch := range checks {
+		wg.Add(1)
+		go func(ch namedCheck) {
+			defer wg.Done()
+			status := "ok"
+			if err := ch.fn(ctx); err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			res[ch.name] = status
+			if status != "ok" && !ch.optional {
+				ok = false
+			}
+		}(ch)
+	}
+	wg.Wait()
+	return res, ok
+}
+
+// Handler отдаёт /healthz (процесс жив) и /readyz (зависимости доступны)
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, report{Status: "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		checks, ok := c.Ready(r.Context())
+		if !ok {
+			writeJSON(w, http.StatusServiceUnavailable, report{Status: "fail", Checks: checks})
+			return
+		}
+		writeJSON(w, http.StatusOK, report{Status: "ok", Checks: checks})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Probe — клиентская сторона для подкоманды `healthcheck` (Docker HEALTHCHECK)
+func Probe(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var rep report
+		_ = json.NewDecoder(resp.Body).Decode(&rep)
+		return fmt.Errorf("%s: status %d %v", url, resp.StatusCode, rep.Checks)
+	}
+	return nil
+}
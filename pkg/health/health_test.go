@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadyTimeout(t *testing.T) {
+	c := NewChecker(50 * time.Millisecond)
+	c.Add("db", func(context.Context) error { return nil })
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	res, ok := c.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Ready took %v, want about the checker timeout", elapsed)
+	}
+	if ok {
+		t.Error("ready with a timed out check")
+	}
+	if res["db"] != "ok" || !strings.Contains(res["slow"], "deadline") {
+		t.Errorf("checks = %v", res)
+	}
+}
+
+func TestHandlerStatus(t *testing.T) {
+	telegram := errors.New("telegram down")
+	db := error(nil)
+	c := NewChecker(time.Second)
+	c.Add("db", func(context.Context) error { return db })
+	c.AddOptional("telegram", func(context.Context) error { return telegram })
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+	ctx := context.Background()
+
+	get := func(path string) int {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// сбой необязательной проверки не снимает готовность
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz with Telegram down = %d, want 200", code)
+	}
+	if err := Probe(ctx, srv.URL+"/readyz"); err != nil {
+		t.Errorf("Probe: %v", err)
+	}
+
+	db = errors.New("no connection")
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with DB down = %d, want 503", code)
+	}
+	if err := Probe(ctx, srv.URL+"/readyz"); err == nil || !strings.Contains(err.Error(), "no connection") {
+		t.Errorf("Probe with DB down: err = %v", err)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", code)
+	}
+}
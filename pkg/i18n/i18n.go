@@ -0,0 +1,99 @@
+// Package i18n — каталоги сообщений и выбор языка пользователя
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fallback — язык, на котором есть все тексты; используется, если в каталоге
+// выбранного языка ключа нет
+const Fallback = "ru"
+
+// Supported — языки, для которых есть каталоги и шаблоны сообщений
+var Supported = []string{"ru", "en"}
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	out := make(map[string]map[string]string)
+	for _, loc := range Supported {
+		b, err := localesFS.ReadFile("locales/" + loc + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", loc, err))
+		}
+		var m map[string]string
+		if err = json.Unmarshal(b, &m); err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", loc, err))
+		}
+		out[loc] = m
+	}
+	return out
+}
+
+// Match приводит language_code Telegram («en-US», «pt-br») к поддерживаемому языку
+func Match(code string) (string, bool) {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	for _, loc := range Supported {
+		if loc == code {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+// Resolve выбирает язык: явный выбор пользователя, затем язык Telegram, затем def
+func Resolve(chosen, languageCode, def string) string {
+	if loc, ok := Match(chosen); ok {
+		return loc
+	}
+	if loc, ok := Match(languageCode); ok {
+		return loc
+	}
+	if loc, ok := Match(def); ok {
+		return loc
+	}
+	return Fallback
+}
+
+// T — строка из каталога; {name} в строке заменяется значениями из args
+// (пары имя, значение)
+func T(locale, key string, args ...string) string {
+	s, ok := catalogs[locale][key]
+	if !ok {
+		if s, ok = catalogs[Fallback][key]; !ok {
+			return key
+		}
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		s = strings.ReplaceAll(s, "{"+args[i]+"}", args[i+1])
+	}
+	return s
+}
+
+// Name — название языка на нём самом («English»)
+func Name(locale string) string { return T(locale, "language.name") }
+
+type ctxKey struct{}
+
+// WithLocale кладёт язык текущего пользователя в контекст
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, locale)
+}
+
+// FromContext — язык из контекста (Fallback, если не задан)
+func FromContext(ctx context.Context) string {
+	if loc, ok := ctx.Value(ctxKey{}).(string); ok && loc != "" {
+		return loc
+	}
+	return Fallback
+}
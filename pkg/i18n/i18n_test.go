@@ -0,0 +1,42 @@
+package i18n
+
+import "testing"
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	for _, loc := range Supported {
+		for key := range catalogs[Fallback] {
+			if _, ok := catalogs[loc][key]; !ok {
+				t.Errorf("%s: missing key %q", loc, key)
+			}
+		}
+		for key := range catalogs[loc] {
+			if _, ok := catalogs[Fallback][key]; !ok {
+				t.Errorf("%s: key %q is not in %s catalog", loc, key, Fallback)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cases := []struct{ chosen, code, def, want string }{
+		{"", "en-US", "ru", "en"},
+		{"", "de", "ru", "ru"},
+		{"", "de", "en", "en"},
+		{"ru", "en", "en", "ru"},
+		{"", "", "xx", Fallback},
+	}
+	for _, c := range cases {
+		if got := Resolve(c.chosen, c.code, c.def); got != c.want {
+			t.Errorf("Resolve(%q, %q, %q) = %q, want %q", c.chosen, c.code, c.def, got, c.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T("en", "language.saved", "lang", "English"); got != "✅ Language saved: English" {
+		t.Errorf("T = %q", got)
+	}
+	if got := T("en", "no.such.key"); got != "no.such.key" {
+		t.Errorf("T(missing) = %q, want key", got)
+	}
+}
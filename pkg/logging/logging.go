@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// New собирает slog.Logger по настройкам из конфига (LOG_LEVEL, LOG_FORMAT)
+func New(w io.Writer, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+	if strings.EqualFold(format, "text") {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+func ParseLevel(s string) slog.Level {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return lvl
+}
+
+// WithLogger кладёт логгер в контекст (логгер апдейта с update_id, user_id, …)
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// With дописывает атрибуты к логгеру из контекста
+func With(ctx context.Context, fallback *slog.Logger, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx, fallback).With(args...))
+}
+
+// FromContext возвращает логгер из контекста или fallback
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return slog.Default()
+}
+
+// BotLogger адаптирует slog к интерфейсу логгера tgbotapi
+type BotLogger struct {
+	L *slog.Logger
+}
+
+func (b BotLogger) Println(v ...interface{}) {
+	b.L.Warn(strings.TrimSpace(fmt.Sprintln(v...)), "component", "tgbotapi")
+}
+
+func (b BotLogger) Printf(format string, v ...interface{}) {
+	b.L.Warn(strings.TrimSpace(fmt.Sprintf(format, v...)), "component", "tgbotapi")
+}
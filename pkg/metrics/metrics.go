@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// Счётчики процесса; отдаются в JSON на /debug/vars
+var counters = expvar.NewMap("counters")
+
+func Inc(name string) { counters.Add(name, 1) }
+
+func Add(name string, delta int64) { counters.Add(name, delta) }
+
+// Publish отдаёт на /debug/vars значение, вычисляемое при запросе
+func Publish(name string, fn func() any) {
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(fn))
+	}
+}
+
+// Handler — expvar-эндпоинт для монтирования на health-сервер
+func Handler() http.Handler { return expvar.Handler() }
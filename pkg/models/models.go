@@ -1,14 +1,72 @@
 package models
 
+import "time"
+
 type Promotion struct {
 	ID       int
 	Name     string
 	Value    string
 	ImageURL string
+	Texts    map[string]PromotionText // переводы по языкам
+}
+
+// PromotionText — название и значение скидки на одном языке
+type PromotionText struct {
+	Name  string
+	Value string
+}
+
+// Localized — название и значение на языке locale; без перевода — основные
+func (p Promotion) Localized(locale string) PromotionText {
+	if t, ok := p.Texts[locale]; ok {
+		return t
+	}
+	return PromotionText{Name: p.Name, Value: p.Value}
+}
+
+// BotUser — профиль собеседника бота
+type BotUser struct {
+	UserID       int64
+	ChatID       int64
+	Username     string
+	FirstName    string
+	LastName     string
+	LanguageCode string // язык из Telegram
+	Language     string // выбран в /language; "" — как в Telegram
+	StartPayload string // параметр первого /start (deep link)
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	Blocked      bool // пользователь заблокировал бота
 }
 
+// UserClaim — участие гостя в розыгрыше
 type UserClaim struct {
-	UserID int64
+	UserID      int64
+	PromotionID int    // 0 — скидку удалили или клейм сделан до учёта
+	Promotion   string // название и значение на момент розыгрыша
+	Value       string
+	ClaimedAt   time.Time // нулевое — клейм сделан до учёта времени
+}
+
+// Ban — гость, которому запрещено участвовать
+type Ban struct {
+	UserID    int64
+	Reason    string
+	BannedBy  int64
+	CreatedAt time.Time
+}
+
+// Captcha — проверка «не бот» перед розыгрышем
+type Captcha struct {
+	UserID    int64
+	Kind      string   // emoji | math
+	Question  string   // текст задачи на языке гостя
+	Options   []string // варианты ответа на кнопках
+	Answer    string
+	Nonce     string    // метка задачи в callback_data: кнопки прежних задач не принимаются
+	Attempts  int       // неверных ответов подряд
+	PassedAt  time.Time // нулевое — ещё не пройдена
+	UpdatedAt time.Time
 }
 
 type AdminState struct {
@@ -16,3 +74,55 @@ type AdminState struct {
 	State  string
 	Data   string
 }
+
+type QueuedUpdate struct {
+	UpdateID int64
+	Payload  []byte
+	Attempts int
+}
+
+// OutboxMessage — отложенное сообщение, которое диспетчер доставит с повторами
+type OutboxMessage struct {
+	ID          int64
+	DedupKey    string
+	ChatID      int64
+	Kind        string // text | photo
+	Text        string
+	ImageURL    string
+	ParseMode   string
+	ReplyMarkup []byte // JSON tgbotapi.InlineKeyboardMarkup
+	SendAfter   time.Time
+	Attempts    int
+	Status      string // pending | sent | failed
+	LastError   string
+}
+
+// Settings — настройки, которые админ меняет на лету через /settings
+type Settings struct {
+	ShopURL        string
+	SubChannelID   int64 // 0 — без проверки подписки
+	SubChannelLink string
+	DrawDelay      time.Duration // пауза между кубиком и призом
+	StartImage     string        // file_id или URL картинки /start; "" — встроенная
+	StartButtons   []LinkButton  // кнопки-ссылки под стартовым сообщением
+
+	// Проверки перед розыгрышем; нулевые значения — проверка выключена
+	MaxUserID       int64         // аккаунты с ID больше — слишком новые
+	RequireUsername bool          // без @username не участвуют
+	RequirePremium  bool          // участвуют только с Telegram Premium
+	MinStartAge     time.Duration // с первого /start должно пройти не меньше
+	DrawFlood       Rate          // попыток розыгрыша на пользователя
+	Captcha         string        // задача перед розыгрышем: emoji | math; "" — без неё
+}
+
+// Rate — не больше N событий за Per; N == 0 — без ограничения
+type Rate struct {
+	N   int
+	Per time.Duration
+}
+
+// LinkButton — кнопка со ссылкой
+type LinkButton struct {
+	Text string
+	URL  string
+}
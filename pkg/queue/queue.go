@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	"github.com/Redarek/go-tg-bot-rest/pkg/workers"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	batchSize = 64
+	// запас аренды сверх ожидания в шарде: подтверждение в БД, раздача пачки
+	leaseMargin = 30 * time.Second
+	pollEvery   = time.Second
+	maxAttempts = 5
+	// Пока апдейт ждёт повтора, следующие апдейты того же пользователя стоят
+	// за ним (порядок в шарде), поэтому пауза — секунды: за ~20 с апдейт
+	// либо проходит, либо уходит в dead и освобождает очередь
+	maxBackoff = 8 * time.Second
+)
+
+// Queue — персистентная очередь апдейтов в Postgres между поллером и воркерами.
+// Апдейт удаляется только после успешной обработки; при ошибке повторяется
+// с экспоненциальной задержкой, после maxAttempts уходит в dead.
+type Queue struct {
+	repo   repositories.UpdateQueue
+	handle func(tgbotapi.Update) error
+	onDead func(tgbotapi.Update) // апдейт ушёл в dead — пользователю пора сказать об ошибке
+	log    *slog.Logger
+	wake   chan struct{}
+
+	handlerTimeout time.Duration // HANDLER_TIMEOUT — из него считается аренда
+
+	pollEvery   time.Duration
+	maxAttempts int
+	maxBackoff  time.Duration
+}
+
+// New: onDead (может быть nil) вызывается, когда апдейт исчерпал попытки;
+// handlerTimeout — предел обработки одного апдейта, по нему считается аренда
+func New(repo repositories.UpdateQueue, handle func(tgbotapi.Update) error,
+	onDead func(tgbotapi.Update), handlerTimeout time.Duration, logger *slog.Logger) *Queue {
+	return &Queue{
+		repo:   repo,
+		handle: handle,
+		onDead: onDead,
+		log:    logger.With("component", "queue"),
+		wake:   make(chan struct{}, 1),
+
+		handlerTimeout: handlerTimeout,
+
+		pollEvery:   pollEvery,
+		maxAttempts: maxAttempts,
+		maxBackoff:  maxBackoff,
+	}
+}
+
+// Push сохраняет апдейт от поллера
+func (q *Queue) Push(ctx context.Context, upd tgbotapi.Update) error {
+	payload, err := json.Marshal(upd)
+	if err != nil {
+		return err
+	}
+	if err = q.repo.EnqueueUpdate(ctx, int64(upd.UpdateID), workers.ShardKey(upd), payload); err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// Run выбирает готовые апдейты и раздаёт их пулу, пока ctx не отменён
+func (q *Queue) Run(ctx context.Context, pool *workers.Pool) {
+	t := time.NewTicker(q.pollEvery)
+	defer t.Stop()
+	lease := q.lease(pool.Depth())
+
+	for {
+		n, err := q.dispatch(ctx, pool, lease)
+		if err != nil && ctx.Err() == nil {
+			q.log.Error("claim updates", "err", err)
+		}
+		if n == batchSize {
+			continue // похоже, есть ещё — не ждём тикер
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-t.C:
+		}
+	}
+}
+
+// lease — аренда выданного апдейта. В шарде пула он может ждать depth
+// апдейтов других пользователей и тот, что уже в обработке, — каждый до
+// handlerTimeout. Истёкшая аренда отдала бы апдейт второй раз, поэтому
+// считаем по худшему случаю.
+func (q *Queue) lease(depth int) time.Duration {
+	return time.Duration(depth+1)*q.handlerTimeout + leaseMargin
+}
+
+func (q *Queue) dispatch(ctx context.Context, pool *workers.Pool, lease time.Duration) (int, error) {
+	items, err := q.repo.ClaimUpdates(ctx, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+	for i, it := range items {
+		var upd tgbotapi.Update
+		if err = json.Unmarshal(it.Payload, &upd); err != nil {
+			q.log.Error("corrupted queued update", "update_id", it.UpdateID, "err", err)
+			_, _ = q.repo.FailUpdate(ctx, it.UpdateID, err.Error(), 0, q.maxBackoff)
+			continue
+		}
+		if err = pool.Enqueue(ctx, upd); err != nil {
+			// остановка: возвращаем аренду всему, что не успели раздать
+			rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			for _, rest := range items[i:] {
+				_ = q.repo.ReleaseUpdate(rctx, rest.UpdateID)
+			}
+			cancel()
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// Process — функция воркера: обработка и подтверждение либо отложенный повтор
+func (q *Queue) Process(upd tgbotapi.Update) {
+	herr := q.handle(upd)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id := int64(upd.UpdateID)
+
+	if herr == nil {
+		if err := q.repo.AckUpdate(ctx, id); err != nil {
+			q.log.Error("ack update", "update_id", id, "err", err)
+		}
+		q.notify() // следующий апдейт этого пользователя стал доступен
+		return
+	}
+
+	metrics.Inc("updates_failed_total")
+	dead, err := q.repo.FailUpdate(ctx, id, herr.Error(), q.maxAttempts, q.maxBackoff)
+	if err != nil {
+		q.log.Error("fail update", "update_id", id, "err", err)
+		return
+	}
+	if dead {
+		metrics.Inc("updates_dead_total")
+		q.log.Error("update moved to dead letter", "update_id", id, "err", herr)
+		if q.onDead != nil {
+			q.onDead(upd)
+		}
+	}
+	q.notify()
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
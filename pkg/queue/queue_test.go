@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	"github.com/Redarek/go-tg-bot-rest/pkg/workers"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// stubRepo — FailUpdate считает попытки и отдаёт dead после maxAttempts
+type stubRepo struct {
+	attempts map[int64]int
+	acked    []int64
+}
+
+func (r *stubRepo) EnqueueUpdate(context.Context, int64, int64, []byte) error { return nil }
+func (r *stubRepo) ClaimUpdates(context.Context, int, time.Duration) ([]models.QueuedUpdate, error) {
+	return nil, nil
+}
+func (r *stubRepo) AckUpdate(_ context.Context, id int64) error {
+	r.acked = append(r.acked, id)
+	return nil
+}
+func (r *stubRepo) FailUpdate(_ context.Context, id int64, _ string, maxAttempts int, _ time.Duration) (bool, error) {
+	r.attempts[id]++
+	return r.attempts[id] >= maxAttempts, nil
+}
+func (r *stubRepo) ReleaseUpdate(context.Context, int64) error { return nil }
+
+func TestProcessReportsDeadUpdate(t *testing.T) {
+	repo := &stubRepo{attempts: map[int64]int{}}
+	var dead []int
+	q := New(repo, func(tgbotapi.Update) error { return errors.New("db down") },
+		func(upd tgbotapi.Update) { dead = append(dead, upd.UpdateID) }, time.Second,
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for i := 1; i < maxAttempts; i++ {
+		q.Process(tgbotapi.Update{UpdateID: 7})
+	}
+	if len(dead) != 0 {
+		t.Fatalf("onDead called after %d attempts", maxAttempts-1)
+	}
+	q.Process(tgbotapi.Update{UpdateID: 7})
+	if len(dead) != 1 || dead[0] != 7 {
+		t.Fatalf("onDead calls = %v, want [7]", dead)
+	}
+}
+
+// Апдейт, который падает, не должен надолго задерживать следующий апдейт
+// того же пользователя: он уходит в dead, и очередь шарда идёт дальше
+func TestFailingUpdateDoesNotBlockUser(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := repositories.NewMemory()
+	user := &tgbotapi.User{ID: 1001}
+
+	var mu sync.Mutex
+	handled := make(chan int, 16)
+	var dead []int
+	q := New(repo, func(upd tgbotapi.Update) error {
+		handled <- upd.UpdateID
+		if upd.Message.Text == "bad" {
+			return errors.New("db down")
+		}
+		return nil
+	}, func(upd tgbotapi.Update) {
+		mu.Lock()
+		dead = append(dead, upd.UpdateID)
+		mu.Unlock()
+	}, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	q.maxBackoff, q.pollEvery = 20*time.Millisecond, 10*time.Millisecond // в проде секунды; здесь ускоряем
+
+	chat := &tgbotapi.Chat{ID: user.ID, Type: "private"}
+	for id, text := range map[int]string{1: "bad", 2: "good"} {
+		upd := tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{From: user, Chat: chat, Text: text}}
+		if err := q.Push(ctx, upd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := workers.NewPool(2, 16, q.Process)
+	pool.Start()
+	defer func() { _ = pool.Shutdown(context.Background()) }()
+	go q.Run(ctx, pool)
+
+	var order []int
+	deadline := time.After(5 * time.Second)
+	for len(order) == 0 || order[len(order)-1] != 2 {
+		select {
+		case id := <-handled:
+			order = append(order, id)
+		case <-deadline:
+			t.Fatalf("good update was not handled, order so far %v", order)
+		}
+	}
+	if want := []int{1, 1, 1, 1, 1, 2}; !reflect.DeepEqual(order, want) {
+		t.Errorf("handled order = %v, want %v", order, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(dead, []int{1}) || !reflect.DeepEqual(repo.DeadUpdates(), []int64{1}) {
+		t.Errorf("dead = %v / %v, want [1]", dead, repo.DeadUpdates())
+	}
+}
+
+// Упавший апдейт откладывается на 2^attempt секунд, но не дольше maxBackoff,
+// и после maxAttempts попыток уходит в dead
+func TestFailedUpdateBackoffAndDead(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewMemory()
+	var dead []int
+	q := New(repo, func(tgbotapi.Update) error { return errors.New("db down") },
+		func(upd tgbotapi.Update) { dead = append(dead, upd.UpdateID) }, time.Second,
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	claim := func(id int64) bool {
+		items, err := repo.ClaimUpdates(ctx, batchSize, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range items {
+			if it.UpdateID == id {
+				return true
+			}
+		}
+		return false
+	}
+	upd := tgbotapi.Update{UpdateID: 7, Message: &tgbotapi.Message{
+		From: &tgbotapi.User{ID: 1001}, Chat: &tgbotapi.Chat{ID: 1001}, Text: "bad"}}
+	if err := q.Push(ctx, upd); err != nil {
+		t.Fatal(err)
+	}
+
+	// первая неудача с прод-настройками: повтор через 2 с, раньше не выдаётся
+	if !claim(7) {
+		t.Fatal("fresh update was not claimed")
+	}
+	start := time.Now()
+	q.Process(upd)
+	at, ok := repo.UpdateAvailableAt(7)
+	if !ok {
+		t.Fatal("failed update was removed from the queue")
+	}
+	if d := at.Sub(start); d < 2*time.Second || d > 2*time.Second+time.Second/2 {
+		t.Errorf("retry delay after attempt 1 = %v, want 2s", d)
+	}
+	if claim(7) {
+		t.Error("update was claimed again before its retry delay")
+	}
+
+	// дальше пауза упирается в maxBackoff; ускоряем, чтобы дойти до dead
+	q.maxBackoff = 30 * time.Millisecond
+	for attempt := 2; attempt <= maxAttempts; attempt++ {
+		deadline := time.Now().Add(5 * time.Second)
+		for !claim(7) {
+			if time.Now().After(deadline) {
+				t.Fatalf("attempt %d: update was not claimed again", attempt)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		start = time.Now()
+		q.Process(upd)
+		if at, _ = repo.UpdateAvailableAt(7); at.Sub(start) > q.maxBackoff+100*time.Millisecond {
+			t.Errorf("attempt %d: retry delay %v exceeds maxBackoff %v", attempt, at.Sub(start), q.maxBackoff)
+		}
+		if attempt < maxAttempts && len(dead) != 0 {
+			t.Fatalf("update went dead after %d attempts, want %d", attempt, maxAttempts)
+		}
+	}
+	if !reflect.DeepEqual(dead, []int{7}) || !reflect.DeepEqual(repo.DeadUpdates(), []int64{7}) {
+		t.Errorf("dead = %v / %v, want [7]", dead, repo.DeadUpdates())
+	}
+	time.Sleep(2 * q.maxBackoff)
+	if claim(7) {
+		t.Error("dead update was claimed again")
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type entry struct {
+	lim  *rate.Limiter
+	seen time.Time
+}
+
+// Keyed — набор лимитеров по ключу (user_id, chat_id) с вытеснением простаивающих
+type Keyed struct {
+	mu        sync.Mutex
+	m         map[int64]*entry
+	newLim    func(key int64) *rate.Limiter
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+// NewKeyed: newLim создаёт лимитер для нового ключа, idle — через сколько
+// простоя запись удаляется
+func NewKeyed(idle time.Duration, newLim func(key int64) *rate.Limiter) *Keyed {
+	return &Keyed{
+		m:         make(map[int64]*entry),
+		newLim:    newLim,
+		idle:      idle,
+		lastSweep: time.Now(),
+	}
+}
+
+func (k *Keyed) Get(key int64) *rate.Limiter {
+	now := time.Now()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if now.Sub(k.lastSweep) > k.idle {
+		k.sweep(now)
+	}
+	e, ok := k.m[key]
+	if !ok {
+		e = &entry{lim: k.newLim(key)}
+		k.m[key] = e
+	}
+	e.seen = now
+	return e.lim
+}
+
+func (k *Keyed) Allow(key int64) bool { return k.Get(key).Allow() }
+
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.m)
+}
+
+// sweep удаляет записи, простаивающие дольше idle; вызывается под mu
+func (k *Keyed) sweep(now time.Time) {
+	for key, e := range k.m {
+		if now.Sub(e.seen) > k.idle {
+			delete(k.m, key)
+		}
+	}
+	k.lastSweep = now
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *Repository) GetCaptcha(ctx context.Context, userID int64) (models.Captcha, bool, error) {
+	var (
+		c        models.Captcha
+		passedAt *time.Time
+	)
+	err := r.DB.QueryRow(ctx, `
+		SELECT user_id, kind, question, options, answer, nonce, attempts, passed_at, updated_at
+		FROM captchas WHERE user_id=$1`, userID).
+		Scan(&c.UserID, &c.Kind, &c.Question, &c.Options, &c.Answer, &c.Nonce, &c.Attempts, &passedAt, &c.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Captcha{}, false, nil
+	}
+	if err != nil {
+		return models.Captcha{}, false, err
+	}
+	if passedAt != nil {
+		c.PassedAt = *passedAt
+	}
+	return c, true, nil
+}
+
+func (r *Repository) SaveCaptcha(ctx context.Context, c models.Captcha) error {
+	var passedAt *time.Time
+	if !c.PassedAt.IsZero() {
+		passedAt = &c.PassedAt
+	}
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO captchas (user_id, kind, question, options, answer, nonce, attempts, passed_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		ON CONFLICT (user_id) DO UPDATE SET
+			kind = EXCLUDED.kind, question = EXCLUDED.question, options = EXCLUDED.options,
+			answer = EXCLUDED.answer, nonce = EXCLUDED.nonce, attempts = EXCLUDED.attempts,
+			passed_at = EXCLUDED.passed_at, updated_at = now()`,
+		c.UserID, c.Kind, c.Question, c.Options, c.Answer, c.Nonce, c.Attempts, passedAt)
+	return err
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *Repository) GetClaim(ctx context.Context, userID int64) (models.UserClaim, bool, error) {
+	var (
+		c           models.UserClaim
+		promotionID *int
+		claimedAt   *time.Time
+	)
+	err := r.DB.QueryRow(ctx, `
+		SELECT user_id, promotion_id, promotion_name, promotion_value, claimed_at
+		FROM user_claims WHERE user_id=$1`, userID).
+		Scan(&c.UserID, &promotionID, &c.Promotion, &c.Value, &claimedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.UserClaim{}, false, nil
+	}
+	if err != nil {
+		return models.UserClaim{}, false, err
+	}
+	if promotionID != nil {
+		c.PromotionID = *promotionID
+	}
+	if claimedAt != nil {
+		c.ClaimedAt = *claimedAt
+	}
+	return c, true, nil
+}
+
+func (r *Repository) ResetClaim(ctx context.Context, userID int64) (bool, error) {
+	ct, err := r.DB.Exec(ctx, `DELETE FROM user_claims WHERE user_id=$1`, userID)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
+func (r *Repository) BanUser(ctx context.Context, b models.Ban) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO bans (user_id, reason, banned_by) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET reason = EXCLUDED.reason, banned_by = EXCLUDED.banned_by`,
+		b.UserID, b.Reason, b.BannedBy)
+	return err
+}
+
+func (r *Repository) UnbanUser(ctx context.Context, userID int64) error {
+	_, err := r.DB.Exec(ctx, `DELETE FROM bans WHERE user_id=$1`, userID)
+	return err
+}
+
+func (r *Repository) GetBan(ctx context.Context, userID int64) (models.Ban, bool, error) {
+	var b models.Ban
+	err := r.DB.QueryRow(ctx, `SELECT user_id, reason, banned_by, created_at FROM bans WHERE user_id=$1`, userID).
+		Scan(&b.UserID, &b.Reason, &b.BannedBy, &b.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Ban{}, false, nil
+	}
+	return b, err == nil, err
+}
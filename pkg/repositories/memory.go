@@ -0,0 +1,630 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+)
+
+// Memory — хранилище в памяти с той же семантикой, что у Repository
+// (уникальность клейма, атомарный ClaimPromotion, порядок outbox внутри чата).
+// Только для тестов: ничего не переживает рестарт.
+type Memory struct {
+	mu         sync.Mutex
+	promotions []models.Promotion
+	nextPromo  int
+	claims     map[int64]models.UserClaim
+	bans       map[int64]models.Ban
+	captchas   map[int64]models.Captcha
+	states     map[int64]models.AdminState
+	users      map[int64]models.BotUser
+	texts      map[int]map[string]models.PromotionText
+	processed  map[int64]bool
+	settings   map[string]string
+	templates  map[string]map[string]string
+	outbox     []*memOutbox
+	nextOutbox int64
+	updates    []*memUpdate
+}
+
+type memUpdate struct {
+	models.QueuedUpdate
+	shardKey    int64
+	status      string
+	availableAt time.Time
+	lockedUntil time.Time
+	lastError   string
+}
+
+type memOutbox struct {
+	models.OutboxMessage
+	status      string
+	lockedUntil time.Time
+	lastError   string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		nextPromo:  1,
+		nextOutbox: 1,
+		claims:     make(map[int64]models.UserClaim),
+		bans:       make(map[int64]models.Ban),
+		captchas:   make(map[int64]models.Captcha),
+		states:     make(map[int64]models.AdminState),
+		users:      make(map[int64]models.BotUser),
+		texts:      make(map[int]map[string]models.PromotionText),
+		processed:  make(map[int64]bool),
+		settings:   make(map[string]string),
+		templates:  make(map[string]map[string]string),
+	}
+}
+
+func (m *Memory) CreatePromotion(_ context.Context, name, value, imageURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.promotions {
+		if p.Name == name {
+			return errors.New("promotion name already exists")
+		}
+	}
+	m.promotions = append(m.promotions, models.Promotion{ID: m.nextPromo, Name: name, Value: value, ImageURL: imageURL})
+	m.nextPromo++
+	return nil
+}
+
+func (m *Memory) UpdatePromotion(_ context.Context, id int, name, value, imageURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.promotions {
+		if p.ID == id {
+			m.promotions[i] = models.Promotion{ID: id, Name: name, Value: value, ImageURL: imageURL}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeletePromotion(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.promotions {
+		if p.ID == id {
+			m.promotions = append(m.promotions[:i], m.promotions[i+1:]...)
+			delete(m.texts, id)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetPromotions(context.Context) ([]models.Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.promotions) == 0 {
+		return nil, nil
+	}
+	list := make([]models.Promotion, len(m.promotions))
+	for i, p := range m.promotions {
+		list[i] = m.withTexts(p)
+	}
+	return list, nil
+}
+
+func (m *Memory) SetPromotionTranslation(_ context.Context, id int, locale string, t models.PromotionText) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.texts[id] == nil {
+		m.texts[id] = make(map[string]models.PromotionText)
+	}
+	m.texts[id][locale] = t
+	return nil
+}
+
+func (m *Memory) DeletePromotionTranslation(_ context.Context, id int, locale string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.texts[id], locale)
+	return nil
+}
+
+// withTexts — копия скидки с переводами; вызывается под m.mu
+func (m *Memory) withTexts(p models.Promotion) models.Promotion {
+	if len(m.texts[p.ID]) == 0 {
+		return p
+	}
+	p.Texts = make(map[string]models.PromotionText, len(m.texts[p.ID]))
+	for loc, t := range m.texts[p.ID] {
+		p.Texts[loc] = t
+	}
+	return p
+}
+
+func (m *Memory) HasUserClaimed(_ context.Context, userID int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.claims[userID]
+	return ok
+}
+
+func (m *Memory) ClaimPromotion(_ context.Context, userID int64, once bool,
+	prize func(models.Promotion) []models.OutboxMessage) (models.Promotion, error) {
+	m.mu.Lock()
+	if _, claimed := m.claims[userID]; once && claimed {
+		m.mu.Unlock()
+		return models.Promotion{}, ErrAlreadyClaimed
+	}
+	if len(m.promotions) == 0 {
+		m.mu.Unlock()
+		return models.Promotion{}, ErrNoPromotions
+	}
+	p := m.withTexts(m.promotions[rand.Intn(len(m.promotions))])
+	if once {
+		m.claims[userID] = models.UserClaim{
+			UserID: userID, PromotionID: p.ID, Promotion: p.Name, Value: p.Value, ClaimedAt: time.Now(),
+		}
+	}
+	m.mu.Unlock()
+
+	// prize вызывается без блокировки: он может читать хранилище (шаблоны, настройки)
+	msgs := prize(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		m.insertOutbox(msg)
+	}
+	return p, nil
+}
+
+func (m *Memory) GetClaim(_ context.Context, userID int64) (models.UserClaim, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.claims[userID]
+	return c, ok, nil
+}
+
+func (m *Memory) ResetClaim(_ context.Context, userID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.claims[userID]
+	delete(m.claims, userID)
+	return ok, nil
+}
+
+func (m *Memory) BanUser(_ context.Context, b models.Ban) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.bans[b.UserID]; ok {
+		b.CreatedAt = old.CreatedAt
+	} else {
+		b.CreatedAt = time.Now()
+	}
+	m.bans[b.UserID] = b
+	return nil
+}
+
+func (m *Memory) UnbanUser(_ context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.bans, userID)
+	return nil
+}
+
+func (m *Memory) GetBan(_ context.Context, userID int64) (models.Ban, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.bans[userID]
+	return b, ok, nil
+}
+
+func (m *Memory) GetCaptcha(_ context.Context, userID int64) (models.Captcha, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.captchas[userID]
+	c.Options = append([]string(nil), c.Options...)
+	return c, ok, nil
+}
+
+func (m *Memory) SaveCaptcha(_ context.Context, c models.Captcha) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.Options = append([]string(nil), c.Options...)
+	c.UpdatedAt = time.Now()
+	m.captchas[c.UserID] = c
+	return nil
+}
+
+func (m *Memory) EnqueueOutbox(_ context.Context, msgs ...models.OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		m.insertOutbox(msg)
+	}
+	return nil
+}
+
+func (m *Memory) OutboxByKeyPrefix(_ context.Context, prefix string) ([]models.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.OutboxMessage
+	for _, o := range m.outbox {
+		if strings.HasPrefix(o.DedupKey, prefix) {
+			list = append(list, o.snapshot())
+		}
+	}
+	return list, nil
+}
+
+func (m *Memory) insertOutbox(msg models.OutboxMessage) {
+	for _, o := range m.outbox {
+		if o.DedupKey == msg.DedupKey {
+			return
+		}
+	}
+	msg.ID = m.nextOutbox
+	m.nextOutbox++
+	if msg.SendAfter.IsZero() {
+		msg.SendAfter = time.Now()
+	}
+	m.outbox = append(m.outbox, &memOutbox{OutboxMessage: msg, status: "pending"})
+}
+
+func (m *Memory) SetAdminState(_ context.Context, st models.AdminState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[st.UserID] = st
+	return nil
+}
+
+func (m *Memory) GetAdminState(_ context.Context, userID int64) (models.AdminState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[userID], nil
+}
+
+func (m *Memory) ClearAdminState(_ context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, userID)
+	return nil
+}
+
+func (m *Memory) UpsertBotUser(_ context.Context, u models.BotUser) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	old, ok := m.users[u.UserID]
+	if !ok {
+		old = models.BotUser{UserID: u.UserID, FirstSeen: now}
+	}
+	old.ChatID, old.Username, old.FirstName, old.LastName = u.ChatID, u.Username, u.FirstName, u.LastName
+	old.LanguageCode = u.LanguageCode
+	if old.StartPayload == "" {
+		old.StartPayload = u.StartPayload
+	}
+	old.LastSeen, old.Blocked = now, false
+	m.users[u.UserID] = old
+	return old.Language, nil
+}
+
+func (m *Memory) GetBotUser(_ context.Context, userID int64) (models.BotUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return models.BotUser{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (m *Memory) FindBotUser(_ context.Context, username string) (models.BotUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found models.BotUser
+	for _, u := range m.users {
+		if u.Username != "" && strings.EqualFold(u.Username, username) && u.LastSeen.After(found.LastSeen) {
+			found = u
+		}
+	}
+	if found.UserID == 0 {
+		return models.BotUser{}, ErrUserNotFound
+	}
+	return found, nil
+}
+
+func (m *Memory) SetUserBlocked(_ context.Context, userID int64, blocked bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[userID]; ok {
+		u.Blocked = blocked
+		m.users[userID] = u
+	}
+	return nil
+}
+
+func (m *Memory) GetUserLanguage(_ context.Context, userID int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.users[userID].Language, nil
+}
+
+func (m *Memory) SetUserLanguage(_ context.Context, userID int64, locale string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		now := time.Now()
+		u = models.BotUser{UserID: userID, ChatID: userID, FirstSeen: now, LastSeen: now}
+	}
+	u.Language = locale
+	m.users[userID] = u
+	return nil
+}
+
+func (m *Memory) IsUpdateProcessed(_ context.Context, updateID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.processed[updateID], nil
+}
+
+func (m *Memory) MarkUpdateProcessed(_ context.Context, updateID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed[updateID] = true
+	return nil
+}
+
+func (m *Memory) GetSettings(context.Context) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]string, len(m.settings))
+	for k, v := range m.settings {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *Memory) SetSetting(_ context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[key] = value
+	return nil
+}
+
+func (m *Memory) DeleteSetting(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.settings, key)
+	return nil
+}
+
+func (m *Memory) GetTemplates(context.Context) (map[string]map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]map[string]string, len(m.templates))
+	for loc, byName := range m.templates {
+		out[loc] = make(map[string]string, len(byName))
+		for k, v := range byName {
+			out[loc][k] = v
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) SetTemplate(_ context.Context, locale, name, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.templates[locale] == nil {
+		m.templates[locale] = make(map[string]string)
+	}
+	m.templates[locale][name] = body
+	return nil
+}
+
+func (m *Memory) DeleteTemplate(_ context.Context, locale, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.templates[locale], name)
+	return nil
+}
+
+func (m *Memory) ClaimOutbox(_ context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	// из каждого чата — только самое раннее неотправленное
+	first := make(map[int64]*memOutbox)
+	for _, o := range m.outbox {
+		if o.status != "pending" {
+			continue
+		}
+		if f, ok := first[o.ChatID]; !ok || o.ID < f.ID {
+			first[o.ChatID] = o
+		}
+	}
+	var ready []*memOutbox
+	for _, o := range first {
+		if !o.SendAfter.After(now) && !o.lockedUntil.After(now) {
+			ready = append(ready, o)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		if !ready[i].SendAfter.Equal(ready[j].SendAfter) {
+			return ready[i].SendAfter.Before(ready[j].SendAfter)
+		}
+		return ready[i].ID < ready[j].ID
+	})
+	if len(ready) > limit {
+		ready = ready[:limit]
+	}
+
+	list := make([]models.OutboxMessage, 0, len(ready))
+	for _, o := range ready {
+		o.lockedUntil = now.Add(lease)
+		o.Attempts++
+		list = append(list, o.OutboxMessage)
+	}
+	return list, nil
+}
+
+func (m *Memory) MarkOutboxSent(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if o := m.findOutbox(id); o != nil {
+		o.status, o.lockedUntil, o.lastError = "sent", time.Time{}, ""
+	}
+	return nil
+}
+
+func (m *Memory) FailOutbox(_ context.Context, id int64, reason string, maxAttempts int, maxBackoff time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o := m.findOutbox(id)
+	if o == nil {
+		return false, errors.New("outbox message not found")
+	}
+	if o.Attempts >= maxAttempts {
+		o.status = "failed"
+	}
+	backoff := min(time.Duration(1<<min(o.Attempts, 30))*time.Second, maxBackoff)
+	o.SendAfter = time.Now().Add(backoff)
+	o.lockedUntil = time.Time{}
+	o.lastError = reason
+	return o.status == "failed", nil
+}
+
+func (m *Memory) findOutbox(id int64) *memOutbox {
+	for _, o := range m.outbox {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// Outbox — снимок всех сообщений outbox (для проверок в тестах)
+func (m *Memory) Outbox() []models.OutboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]models.OutboxMessage, 0, len(m.outbox))
+	for _, o := range m.outbox {
+		list = append(list, o.snapshot())
+	}
+	return list
+}
+
+func (o *memOutbox) snapshot() models.OutboxMessage {
+	msg := o.OutboxMessage
+	msg.Status, msg.LastError = o.status, o.lastError
+	return msg
+}
+
+func (m *Memory) EnqueueUpdate(_ context.Context, updateID, shardKey int64, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.updates {
+		if u.UpdateID == updateID {
+			return nil
+		}
+	}
+	m.updates = append(m.updates, &memUpdate{
+		QueuedUpdate: models.QueuedUpdate{UpdateID: updateID, Payload: payload},
+		shardKey:     shardKey, status: "pending", availableAt: time.Now(),
+	})
+	sort.Slice(m.updates, func(i, j int) bool { return m.updates[i].UpdateID < m.updates[j].UpdateID })
+	return nil
+}
+
+// ClaimUpdates — как в Postgres: из шарда выдаётся только самый ранний pending
+func (m *Memory) ClaimUpdates(_ context.Context, limit int, lease time.Duration) ([]models.QueuedUpdate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	head := make(map[int64]bool)
+	var list []models.QueuedUpdate
+	for _, u := range m.updates {
+		if u.status != "pending" || head[u.shardKey] {
+			continue
+		}
+		head[u.shardKey] = true
+		if len(list) == limit || u.availableAt.After(now) || u.lockedUntil.After(now) {
+			continue
+		}
+		u.lockedUntil = now.Add(lease)
+		u.Attempts++
+		list = append(list, u.QueuedUpdate)
+	}
+	return list, nil
+}
+
+func (m *Memory) AckUpdate(_ context.Context, updateID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range m.updates {
+		if u.UpdateID == updateID {
+			m.updates = append(m.updates[:i], m.updates[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Memory) FailUpdate(_ context.Context, updateID int64, reason string, maxAttempts int, maxBackoff time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.updates {
+		if u.UpdateID != updateID {
+			continue
+		}
+		if u.Attempts >= maxAttempts {
+			u.status = "dead"
+		}
+		u.availableAt = time.Now().Add(min(time.Duration(1<<u.Attempts)*time.Second, maxBackoff))
+		u.lockedUntil, u.lastError = time.Time{}, reason
+		return u.status == "dead", nil
+	}
+	return false, errors.New("update not found")
+}
+
+func (m *Memory) ReleaseUpdate(_ context.Context, updateID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.updates {
+		if u.UpdateID == updateID {
+			u.lockedUntil = time.Time{}
+			u.Attempts = max(u.Attempts-1, 0)
+		}
+	}
+	return nil
+}
+
+// UpdateAvailableAt — когда апдейт снова можно взять из очереди, для тестов
+func (m *Memory) UpdateAvailableAt(updateID int64) (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.updates {
+		if u.UpdateID == updateID {
+			return u.availableAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// DeadUpdates — update_id в dead, для тестов
+func (m *Memory) DeadUpdates() []int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int64
+	for _, u := range m.updates {
+		if u.status == "dead" {
+			ids = append(ids, u.UpdateID)
+		}
+	}
+	return ids
+}
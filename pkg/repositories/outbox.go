@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrAlreadyClaimed = errors.New("already_claimed")
+
+// ClaimPromotion в одной транзакции: фиксирует клейм (если once), выбирает
+// случайную скидку и кладёт сообщения с призом в outbox. Либо пользователь
+// получает и клейм, и гарантированную доставку приза, либо ничего.
+func (r *Repository) ClaimPromotion(ctx context.Context, userID int64, once bool,
+	prize func(models.Promotion) []models.OutboxMessage) (models.Promotion, error) {
+
+	var p models.Promotion
+	err := pgx.BeginFunc(ctx, r.DB, func(tx pgx.Tx) error {
+		if once {
+			ct, err := tx.Exec(ctx, `
+				INSERT INTO user_claims (user_id) VALUES ($1)
+				ON CONFLICT (user_id) DO NOTHING`, userID)
+			if err != nil {
+				return err
+			}
+			if ct.RowsAffected() != 1 {
+				return ErrAlreadyClaimed
+			}
+		}
+
+		err := tx.QueryRow(ctx,
+			`SELECT id, name, value, image_url FROM promotions ORDER BY RANDOM() LIMIT 1`).
+			Scan(&p.ID, &p.Name, &p.Value, &p.ImageURL)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoPromotions
+		}
+		if err != nil {
+			return err
+		}
+		list := []models.Promotion{p}
+		if err = loadTranslations(ctx, tx, list); err != nil {
+			return err
+		}
+		p = list[0]
+		if once {
+			if _, err = tx.Exec(ctx, `
+				UPDATE user_claims SET promotion_id=$2, promotion_name=$3, promotion_value=$4
+				WHERE user_id=$1`, userID, p.ID, p.Name, p.Value); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range prize(p) {
+			if err = insertOutbox(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	return p, nil
+}
+
+// EnqueueOutbox ставит сообщения в outbox одной транзакцией
+func (r *Repository) EnqueueOutbox(ctx context.Context, msgs ...models.OutboxMessage) error {
+	return pgx.BeginFunc(ctx, r.DB, func(tx pgx.Tx) error {
+		for _, m := range msgs {
+			if err := insertOutbox(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) OutboxByKeyPrefix(ctx context.Context, prefix string) ([]models.OutboxMessage, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT id, dedup_key, chat_id, kind, text, image_url, parse_mode, send_after, attempts,
+		       status, COALESCE(last_error, '')
+		FROM outbox
+		WHERE dedup_key LIKE $1 || '%'
+		ORDER BY id`, strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.OutboxMessage
+	for rows.Next() {
+		var m models.OutboxMessage
+		if err = rows.Scan(&m.ID, &m.DedupKey, &m.ChatID, &m.Kind, &m.Text, &m.ImageURL, &m.ParseMode,
+			&m.SendAfter, &m.Attempts, &m.Status, &m.LastError); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func insertOutbox(ctx context.Context, tx pgx.Tx, m models.OutboxMessage) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO outbox (dedup_key, chat_id, kind, text, image_url, parse_mode, reply_markup, send_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (dedup_key) DO NOTHING`,
+		m.DedupKey, m.ChatID, m.Kind, m.Text, m.ImageURL, m.ParseMode, m.ReplyMarkup, m.SendAfter)
+	return err
+}
+
+// ClaimOutbox берёт до limit сообщений, которым пора уйти, под аренду lease.
+// Из каждого чата выдаётся только самое раннее неотправленное — порядок сохраняется.
+func (r *Repository) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH next AS (
+			SELECT o.id
+			FROM outbox o
+			WHERE o.status = 'pending'
+			  AND o.send_after <= now()
+			  AND (o.locked_until IS NULL OR o.locked_until < now())
+			  AND NOT EXISTS (
+				SELECT 1 FROM outbox e
+				WHERE e.chat_id = o.chat_id AND e.status = 'pending' AND e.id < o.id
+			  )
+			ORDER BY o.send_after, o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox u
+		SET locked_until = now() + $2 * interval '1 second',
+		    attempts = u.attempts + 1
+		FROM next
+		WHERE u.id = next.id
+		RETURNING u.id, u.dedup_key, u.chat_id, u.kind, u.text, u.image_url, u.parse_mode,
+		          u.reply_markup, u.send_after, u.attempts`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.OutboxMessage
+	for rows.Next() {
+		var m models.OutboxMessage
+		if err = rows.Scan(&m.ID, &m.DedupKey, &m.ChatID, &m.Kind, &m.Text, &m.ImageURL, &m.ParseMode,
+			&m.ReplyMarkup, &m.SendAfter, &m.Attempts); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (r *Repository) MarkOutboxSent(ctx context.Context, id int64) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE outbox SET status='sent', sent_at=now(), locked_until=NULL, last_error=NULL
+		WHERE id=$1`, id)
+	return err
+}
+
+// FailOutbox откладывает сообщение с экспоненциальной задержкой (не больше maxBackoff);
+// после maxAttempts попыток сообщение помечается failed. Возвращает true, если failed.
+func (r *Repository) FailOutbox(ctx context.Context, id int64, reason string, maxAttempts int, maxBackoff time.Duration) (bool, error) {
+	var failed bool
+	err := r.DB.QueryRow(ctx, `
+		UPDATE outbox
+		SET status       = CASE WHEN attempts >= $3 THEN 'failed' ELSE 'pending' END,
+		    send_after   = now() + LEAST(power(2, attempts), $4) * interval '1 second',
+		    locked_until = NULL,
+		    last_error   = $2
+		WHERE id = $1
+		RETURNING status = 'failed'`, id, reason, maxAttempts, maxBackoff.Seconds()).Scan(&failed)
+	return failed, err
+}
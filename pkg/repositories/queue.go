@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const lastUpdateKey = "last_update_id"
+
+// EnqueueUpdate сохраняет апдейт в очередь и сдвигает сохранённый offset поллинга;
+// повтор того же update_id игнорируется
+func (r *Repository) EnqueueUpdate(ctx context.Context, updateID, shardKey int64, payload []byte) error {
+	_, err := r.DB.Exec(ctx, `
+		WITH q AS (
+			INSERT INTO update_queue (update_id, shard_key, payload)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (update_id) DO NOTHING
+		)
+		INSERT INTO bot_state (key, value) VALUES ($4, $1)
+		ON CONFLICT (key) DO UPDATE SET value = GREATEST(bot_state.value, EXCLUDED.value)`,
+		updateID, shardKey, payload, lastUpdateKey)
+	return err
+}
+
+// LastUpdateID — последний принятый update_id (0, если ещё не было)
+func (r *Repository) LastUpdateID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.DB.QueryRow(ctx, `SELECT value FROM bot_state WHERE key=$1`, lastUpdateKey).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+func (r *Repository) IsUpdateProcessed(ctx context.Context, updateID int64) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM processed_updates WHERE update_id=$1)`, updateID).Scan(&exists)
+	return exists, err
+}
+
+func (r *Repository) MarkUpdateProcessed(ctx context.Context, updateID int64) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO processed_updates (update_id) VALUES ($1)
+		ON CONFLICT (update_id) DO NOTHING`, updateID)
+	return err
+}
+
+// PruneProcessedUpdates удаляет отметки старше olderThan: Telegram хранит
+// неподтверждённые апдейты не дольше суток, дальше повтор невозможен
+func (r *Repository) PruneProcessedUpdates(ctx context.Context, olderThan time.Duration) (int64, error) {
+	ct, err := r.DB.Exec(ctx,
+		`DELETE FROM processed_updates WHERE processed_at < now() - $1 * interval '1 second'`,
+		olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
+
+// ClaimUpdates берёт до limit готовых апдейтов под аренду lease.
+// Из каждого shard_key выдаётся только самый ранний незавершённый апдейт,
+// поэтому апдейты одного пользователя обрабатываются строго по порядку.
+func (r *Repository) ClaimUpdates(ctx context.Context, limit int, lease time.Duration) ([]models.QueuedUpdate, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH next AS (
+			SELECT q.update_id
+			FROM update_queue q
+			WHERE q.status = 'pending'
+			  AND q.available_at <= now()
+			  AND (q.locked_until IS NULL OR q.locked_until < now())
+			  AND NOT EXISTS (
+				SELECT 1 FROM update_queue e
+				WHERE e.shard_key = q.shard_key AND e.status = 'pending' AND e.update_id < q.update_id
+			  )
+			ORDER BY q.update_id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE update_queue u
+		SET locked_until = now() + $2 * interval '1 second',
+		    attempts = u.attempts + 1
+		FROM next
+		WHERE u.update_id = next.update_id
+		RETURNING u.update_id, u.payload, u.attempts`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.QueuedUpdate
+	for rows.Next() {
+		var q models.QueuedUpdate
+		if err = rows.Scan(&q.UpdateID, &q.Payload, &q.Attempts); err != nil {
+			return nil, err
+		}
+		list = append(list, q)
+	}
+	return list, rows.Err()
+}
+
+// AckUpdate — апдейт обработан, удаляем из очереди
+func (r *Repository) AckUpdate(ctx context.Context, updateID int64) error {
+	_, err := r.DB.Exec(ctx, `DELETE FROM update_queue WHERE update_id=$1`, updateID)
+	return err
+}
+
+// FailUpdate откладывает апдейт с экспоненциальной задержкой (не больше maxBackoff);
+// после maxAttempts попыток апдейт уходит в dead и больше не выдаётся.
+// Возвращает true, если апдейт помечен как dead.
+func (r *Repository) FailUpdate(ctx context.Context, updateID int64, reason string, maxAttempts int, maxBackoff time.Duration) (bool, error) {
+	var dead bool
+	err := r.DB.QueryRow(ctx, `
+		UPDATE update_queue
+		SET status       = CASE WHEN attempts >= $3 THEN 'dead' ELSE 'pending' END,
+		    available_at = now() + LEAST(power(2, attempts), $4) * interval '1 second',
+		    locked_until = NULL,
+		    last_error   = $2
+		WHERE update_id = $1
+		RETURNING status = 'dead'`, updateID, reason, maxAttempts, maxBackoff.Seconds()).Scan(&dead)
+	return dead, err
+}
+
+// ReleaseUpdate снимает аренду без штрафа (апдейт не успели обработать)
+func (r *Repository) ReleaseUpdate(ctx context.Context, updateID int64) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE update_queue SET locked_until = NULL, attempts = GREATEST(attempts - 1, 0)
+		WHERE update_id=$1`, updateID)
+	return err
+}
@@ -3,23 +3,31 @@ package repositories
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"math/rand"
 	"time"
 
+	"github.com/Redarek/go-tg-bot-rest/pkg/logging"
 	"github.com/Redarek/go-tg-bot-rest/pkg/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var ErrNoPromotions = errors.New("no_promotions")
+var (
+	ErrNoPromotions = errors.New("no_promotions")
+	ErrUserNotFound = errors.New("user_not_found")
+)
 
 func init() { rand.Seed(time.Now().UnixNano()) }
 
 type Repository struct {
-	DB *pgxpool.Pool
+	DB  *pgxpool.Pool
+	log *slog.Logger
 }
 
-func NewRepository(db *pgxpool.Pool) *Repository { return &Repository{DB: db} }
+func NewRepository(db *pgxpool.Pool, logger *slog.Logger) *Repository {
+	return &Repository{DB: db, log: logger}
+}
 
 func (r *Repository) CreatePromotion(ctx context.Context, name, value, imageURL string) error {
 	_, err := r.DB.Exec(ctx, `INSERT INTO promotions (name, value, image_url) VALUES ($1, $2, $3)`, name, value, imageURL)
@@ -51,48 +59,24 @@ func (r *Repository) GetPromotions(ctx context.Context) ([]models.Promotion, err
 		}
 		list = append(list, p)
 	}
-	return list, rows.Err()
-}
-
-func (r *Repository) GetRandomPromotion(ctx context.Context) (models.Promotion, error) {
-	var p models.Promotion
-	err := r.DB.QueryRow(ctx,
-		`SELECT id, name, value, image_url FROM promotions ORDER BY RANDOM() LIMIT 1`).
-		Scan(&p.ID, &p.Name, &p.Value, &p.ImageURL)
-
-	if errors.Is(err, pgx.ErrNoRows) {
-		return models.Promotion{}, errors.New("список скидок пуст")
-	}
-	return p, err
-}
-
-// Атомарная попытка получить право на скидку 1 раз на user_id
-func (r *Repository) TryClaim(ctx context.Context, userID int64) (bool, error) {
-	ct, err := r.DB.Exec(ctx, `
-		INSERT INTO user_claims (user_id) VALUES ($1)
-		ON CONFLICT (user_id) DO NOTHING
-	`, userID)
-	if err != nil {
-		return false, err
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
-	return ct.RowsAffected() == 1, nil
+	rows.Close()
+	return list, loadTranslations(ctx, r.DB, list)
 }
 
 func (r *Repository) HasUserClaimed(ctx context.Context, userID int64) bool {
 	var exists bool
-	_ = r.DB.QueryRow(ctx,
+	err := r.DB.QueryRow(ctx,
 		`SELECT EXISTS(SELECT 1 FROM user_claims WHERE user_id=$1)`, userID).
 		Scan(&exists)
+	if err != nil {
+		logging.FromContext(ctx, r.log).Error("HasUserClaimed", "err", err)
+	}
 	return exists
 }
 
-func (r *Repository) MarkUserClaimed(ctx context.Context, userID int64) error {
-	_, err := r.DB.Exec(ctx,
-		`INSERT INTO user_claims (user_id) VALUES ($1)`,
-		userID)
-	return err
-}
-
 func (r *Repository) SetAdminState(ctx context.Context, st models.AdminState) error {
 	_, err := r.DB.Exec(ctx, `
 		INSERT INTO admin_states (user_id, state, data)
@@ -118,9 +102,71 @@ func (r *Repository) ClearAdminState(ctx context.Context, userID int64) error {
 	return err
 }
 
-func (r *Repository) UpsertBotUser(ctx context.Context, userID int64) error {
-	_, err := r.DB.Exec(ctx,
-		`INSERT INTO bot_users (user_id) VALUES ($1)
-         ON CONFLICT (user_id) DO NOTHING`, userID)
+func (r *Repository) UpsertBotUser(ctx context.Context, u models.BotUser) (string, error) {
+	var language string
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO bot_users (user_id, chat_id, username, first_name, last_name, language_code, start_payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			chat_id       = EXCLUDED.chat_id,
+			username      = EXCLUDED.username,
+			first_name    = EXCLUDED.first_name,
+			last_name     = EXCLUDED.last_name,
+			language_code = EXCLUDED.language_code,
+			start_payload = CASE WHEN bot_users.start_payload = '' THEN EXCLUDED.start_payload
+			                     ELSE bot_users.start_payload END,
+			last_seen_at  = now(),
+			blocked       = false
+		RETURNING COALESCE(language, '')`,
+		u.UserID, u.ChatID, u.Username, u.FirstName, u.LastName, u.LanguageCode, u.StartPayload).Scan(&language)
+	return language, err
+}
+
+const botUserColumns = `user_id, chat_id, username, first_name, last_name, language_code,
+	COALESCE(language, ''), start_payload, first_seen_at, last_seen_at, blocked`
+
+func scanBotUser(row pgx.Row) (models.BotUser, error) {
+	var u models.BotUser
+	err := row.Scan(&u.UserID, &u.ChatID, &u.Username, &u.FirstName, &u.LastName, &u.LanguageCode,
+		&u.Language, &u.StartPayload, &u.FirstSeen, &u.LastSeen, &u.Blocked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.BotUser{}, ErrUserNotFound
+	}
+	return u, err
+}
+
+func (r *Repository) GetBotUser(ctx context.Context, userID int64) (models.BotUser, error) {
+	return scanBotUser(r.DB.QueryRow(ctx,
+		`SELECT `+botUserColumns+` FROM bot_users WHERE user_id=$1`, userID))
+}
+
+func (r *Repository) FindBotUser(ctx context.Context, username string) (models.BotUser, error) {
+	return scanBotUser(r.DB.QueryRow(ctx,
+		`SELECT `+botUserColumns+` FROM bot_users WHERE lower(username)=lower($1) AND username <> ''
+		 ORDER BY last_seen_at DESC LIMIT 1`, username))
+}
+
+func (r *Repository) SetUserBlocked(ctx context.Context, userID int64, blocked bool) error {
+	_, err := r.DB.Exec(ctx, `UPDATE bot_users SET blocked=$2 WHERE user_id=$1`, userID, blocked)
+	return err
+}
+
+func (r *Repository) GetUserLanguage(ctx context.Context, userID int64) (string, error) {
+	var lang *string
+	err := r.DB.QueryRow(ctx, `SELECT language FROM bot_users WHERE user_id=$1`, userID).Scan(&lang)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil || lang == nil {
+		return "", err
+	}
+	return *lang, nil
+}
+
+// SetUserLanguage запоминает выбор из /language; "" — снова как в Telegram
+func (r *Repository) SetUserLanguage(ctx context.Context, userID int64, locale string) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO bot_users (user_id, chat_id, language) VALUES ($1, $1, NULLIF($2, ''))
+		ON CONFLICT (user_id) DO UPDATE SET language = EXCLUDED.language`, userID, locale)
 	return err
 }
@@ -0,0 +1,474 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/db"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// stores — реализации, на которых гоняется общий набор тестов:
+// Memory всегда, Postgres — если задан TEST_DATABASE_URL
+func stores(t *testing.T) map[string]func(t *testing.T) Store {
+	s := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemory() },
+	}
+	if os.Getenv("TEST_DATABASE_URL") != "" {
+		s["postgres"] = func(t *testing.T) Store {
+			return NewRepository(testPool(t), slog.New(slog.NewTextHandler(io.Discard, nil)))
+		}
+	}
+	return s
+}
+
+// testPool — пул к отдельной схеме в TEST_DATABASE_URL со встроенными миграциями
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err = admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err = db.MigrateUp(ctx, pool, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return pool
+}
+
+func TestClaimPromotionConcurrent(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			if err := s.CreatePromotion(ctx, "ten", "-10%", ""); err != nil {
+				t.Fatal(err)
+			}
+			noPrize := func(models.Promotion) []models.OutboxMessage { return nil }
+
+			const n = 32
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			won := 0
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.ClaimPromotion(ctx, 42, true, noPrize)
+					switch {
+					case err == nil:
+						mu.Lock()
+						won++
+						mu.Unlock()
+					case !errors.Is(err, ErrAlreadyClaimed):
+						t.Errorf("ClaimPromotion: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if won != 1 {
+				t.Fatalf("%d of %d concurrent claims won, want exactly 1", won, n)
+			}
+			if !s.HasUserClaimed(ctx, 42) {
+				t.Error("HasUserClaimed = false after claim")
+			}
+			if s.HasUserClaimed(ctx, 43) {
+				t.Error("HasUserClaimed = true for another user")
+			}
+		})
+	}
+}
+
+func TestClaimPromotionOnce(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			prize := func(p models.Promotion) []models.OutboxMessage {
+				return []models.OutboxMessage{{DedupKey: "claim:7", ChatID: 7, Kind: "text", Text: p.Value, SendAfter: time.Now()}}
+			}
+
+			if _, err := s.ClaimPromotion(ctx, 7, true, prize); !errors.Is(err, ErrNoPromotions) {
+				t.Fatalf("claim without promotions: err = %v, want ErrNoPromotions", err)
+			}
+			if s.HasUserClaimed(ctx, 7) {
+				t.Fatal("failed claim must not be recorded")
+			}
+
+			if err := s.CreatePromotion(ctx, "ten", "-10%", ""); err != nil {
+				t.Fatal(err)
+			}
+
+			const n = 16
+			var wg sync.WaitGroup
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.ClaimPromotion(ctx, 7, true, prize)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			ok := 0
+			for err := range errs {
+				switch {
+				case err == nil:
+					ok++
+				case !errors.Is(err, ErrAlreadyClaimed):
+					t.Errorf("ClaimPromotion: %v", err)
+				}
+			}
+			if ok != 1 {
+				t.Fatalf("%d concurrent claims succeeded, want 1", ok)
+			}
+
+			msgs, err := s.ClaimOutbox(ctx, 10, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != 1 || msgs[0].Text != "-10%" {
+				t.Fatalf("outbox = %+v, want one prize message", msgs)
+			}
+
+			// без once (админ) клейм повторяется
+			for i := 0; i < 2; i++ {
+				if _, err := s.ClaimPromotion(ctx, 1, false, func(models.Promotion) []models.OutboxMessage { return nil }); err != nil {
+					t.Fatalf("repeated claim: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminStateTransitions(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+
+			st, err := s.GetAdminState(ctx, 1)
+			if err != nil || st.State != "" {
+				t.Fatalf("initial state = %+v, %v; want empty", st, err)
+			}
+
+			steps := []models.AdminState{
+				{UserID: 1, State: "add_wait_name"},
+				{UserID: 1, State: "add_wait_value", Data: "ten"},
+				{UserID: 1, State: "add_wait_image", Data: "ten|-10%"},
+			}
+			for _, want := range steps {
+				if err = s.SetAdminState(ctx, want); err != nil {
+					t.Fatal(err)
+				}
+				got, err := s.GetAdminState(ctx, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Fatalf("state = %+v, want %+v", got, want)
+				}
+			}
+
+			if st, _ = s.GetAdminState(ctx, 2); st.State != "" {
+				t.Errorf("other admin state = %+v, want empty", st)
+			}
+
+			if err = s.ClearAdminState(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+			if st, err = s.GetAdminState(ctx, 1); err != nil || st.State != "" {
+				t.Fatalf("state after clear = %+v, %v; want empty", st, err)
+			}
+		})
+	}
+}
+
+func TestPromotionCRUD(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			noPrize := func(models.Promotion) []models.OutboxMessage { return nil }
+
+			if list, err := s.GetPromotions(ctx); err != nil || len(list) != 0 {
+				t.Fatalf("initial list = %v, %v; want empty", list, err)
+			}
+			if _, err := s.ClaimPromotion(ctx, 1, false, noPrize); !errors.Is(err, ErrNoPromotions) {
+				t.Errorf("claim on empty table: err = %v, want ErrNoPromotions", err)
+			}
+
+			if err := s.CreatePromotion(ctx, "ten", "-10%", "https://img/10"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreatePromotion(ctx, "twenty", "-20%", ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreatePromotion(ctx, "ten", "dup", ""); err == nil {
+				t.Error("duplicate name: want error")
+			}
+
+			list, err := s.GetPromotions(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2 || list[0].Name != "ten" || list[1].Name != "twenty" {
+				t.Fatalf("list = %+v", list)
+			}
+
+			id := list[0].ID
+			if err = s.UpdatePromotion(ctx, id, "fifteen", "-15%", ""); err != nil {
+				t.Fatal(err)
+			}
+			if err = s.DeletePromotion(ctx, list[1].ID); err != nil {
+				t.Fatal(err)
+			}
+
+			list, _ = s.GetPromotions(ctx)
+			want := models.Promotion{ID: id, Name: "fifteen", Value: "-15%"}
+			if len(list) != 1 || !reflect.DeepEqual(list[0], want) {
+				t.Fatalf("list = %+v, want [%+v]", list, want)
+			}
+			if p, err := s.ClaimPromotion(ctx, 1, false, noPrize); err != nil || p.ID != want.ID || p.Value != want.Value {
+				t.Fatalf("claimed = %+v, %v; want %+v", p, err, want)
+			}
+		})
+	}
+}
+
+func TestPromotionTranslations(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			if err := s.CreatePromotion(ctx, "Десятка", "-10%", ""); err != nil {
+				t.Fatal(err)
+			}
+			list, _ := s.GetPromotions(ctx)
+			id := list[0].ID
+
+			en := models.PromotionText{Name: "Ten", Value: "10% off"}
+			if err := s.SetPromotionTranslation(ctx, id, "en", en); err != nil {
+				t.Fatal(err)
+			}
+			prize := func(models.Promotion) []models.OutboxMessage { return nil }
+			p, err := s.ClaimPromotion(ctx, 7, false, prize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Localized("en"); got != en {
+				t.Errorf("Localized(en) = %+v, want %+v", got, en)
+			}
+			if got := p.Localized("ru"); got.Value != "-10%" {
+				t.Errorf("Localized(ru) = %+v, want the base text", got)
+			}
+
+			if err = s.DeletePromotionTranslation(ctx, id, "en"); err != nil {
+				t.Fatal(err)
+			}
+			list, _ = s.GetPromotions(ctx)
+			if len(list[0].Texts) != 0 {
+				t.Errorf("texts after delete = %+v", list[0].Texts)
+			}
+		})
+	}
+}
+
+func TestUserLanguage(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			if lang, err := s.GetUserLanguage(ctx, 7); err != nil || lang != "" {
+				t.Fatalf("unknown user: %q, %v", lang, err)
+			}
+			if err := s.SetUserLanguage(ctx, 7, "en"); err != nil {
+				t.Fatal(err)
+			}
+			if lang, _ := s.GetUserLanguage(ctx, 7); lang != "en" {
+				t.Errorf("language = %q, want en", lang)
+			}
+			if err := s.SetUserLanguage(ctx, 7, ""); err != nil {
+				t.Fatal(err)
+			}
+			if lang, _ := s.GetUserLanguage(ctx, 7); lang != "" {
+				t.Errorf("language after reset = %q, want empty", lang)
+			}
+		})
+	}
+}
+
+func TestBotUserProfile(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			if _, err := s.GetBotUser(ctx, 7); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("unknown user: err = %v, want ErrUserNotFound", err)
+			}
+
+			u := models.BotUser{UserID: 7, ChatID: 7, Username: "Ann_Bar", FirstName: "Ann", LanguageCode: "en", StartPayload: "promo1"}
+			if lang, err := s.UpsertBotUser(ctx, u); err != nil || lang != "" {
+				t.Fatalf("upsert: %q, %v", lang, err)
+			}
+			if err := s.SetUserBlocked(ctx, 7, true); err != nil {
+				t.Fatal(err)
+			}
+			_ = s.SetUserLanguage(ctx, 7, "ru")
+			u.FirstName, u.StartPayload = "Anna", "promo2"
+			if lang, err := s.UpsertBotUser(ctx, u); err != nil || lang != "ru" {
+				t.Fatalf("upsert: %q, %v, want the chosen language", lang, err)
+			}
+
+			got, err := s.FindBotUser(ctx, "ann_bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.UserID != 7 || got.FirstName != "Anna" || got.LanguageCode != "en" {
+				t.Errorf("profile = %+v", got)
+			}
+			if got.StartPayload != "promo1" {
+				t.Errorf("start payload = %q, want the first one", got.StartPayload)
+			}
+			if got.Blocked {
+				t.Error("blocked after a new interaction, want unblocked")
+			}
+			if got.FirstSeen.IsZero() || got.LastSeen.Before(got.FirstSeen) {
+				t.Errorf("first/last seen = %v / %v", got.FirstSeen, got.LastSeen)
+			}
+			if _, err = s.FindBotUser(ctx, "nobody"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("FindBotUser(nobody): err = %v", err)
+			}
+		})
+	}
+}
+
+func TestClaimResetAndBans(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			if err := s.CreatePromotion(ctx, "ten", "-10%", ""); err != nil {
+				t.Fatal(err)
+			}
+			prize := func(p models.Promotion) []models.OutboxMessage {
+				return []models.OutboxMessage{{DedupKey: fmt.Sprintf("claim:7:%d:prize", time.Now().UnixNano()), ChatID: 7, Kind: "text", Text: p.Value, SendAfter: time.Now()}}
+			}
+			p, err := s.ClaimPromotion(ctx, 7, true, prize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, ok, err := s.GetClaim(ctx, 7)
+			if err != nil || !ok {
+				t.Fatalf("GetClaim = %v, %v", ok, err)
+			}
+			if c.PromotionID != p.ID || c.Promotion != "ten" || c.Value != "-10%" || c.ClaimedAt.IsZero() {
+				t.Errorf("claim = %+v", c)
+			}
+			sent, err := s.OutboxByKeyPrefix(ctx, "claim:7:")
+			if err != nil || len(sent) != 1 || sent[0].Status != "pending" {
+				t.Fatalf("OutboxByKeyPrefix = %+v, %v", sent, err)
+			}
+			if other, _ := s.OutboxByKeyPrefix(ctx, "claim:70:"); len(other) != 0 {
+				t.Errorf("prefix of another user matched: %+v", other)
+			}
+
+			if reset, err := s.ResetClaim(ctx, 7); err != nil || !reset {
+				t.Fatalf("ResetClaim = %v, %v", reset, err)
+			}
+			if reset, _ := s.ResetClaim(ctx, 7); reset {
+				t.Error("second reset reported a claim")
+			}
+			if _, err = s.ClaimPromotion(ctx, 7, true, prize); err != nil {
+				t.Fatalf("claim after reset: %v", err)
+			}
+			if sent, _ = s.OutboxByKeyPrefix(ctx, "claim:7:"); len(sent) != 2 {
+				t.Errorf("deliveries after re-claim = %d, want 2", len(sent))
+			}
+
+			if _, banned, _ := s.GetBan(ctx, 7); banned {
+				t.Fatal("banned before BanUser")
+			}
+			if err = s.BanUser(ctx, models.Ban{UserID: 7, Reason: "bots", BannedBy: 1}); err != nil {
+				t.Fatal(err)
+			}
+			b, banned, err := s.GetBan(ctx, 7)
+			if err != nil || !banned || b.Reason != "bots" || b.BannedBy != 1 {
+				t.Fatalf("GetBan = %+v, %v, %v", b, banned, err)
+			}
+			if err = s.UnbanUser(ctx, 7); err != nil {
+				t.Fatal(err)
+			}
+			if _, banned, _ = s.GetBan(ctx, 7); banned {
+				t.Error("still banned after UnbanUser")
+			}
+		})
+	}
+}
+
+func TestCaptchaState(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			if _, found, err := s.GetCaptcha(ctx, 7); err != nil || found {
+				t.Fatalf("GetCaptcha before save = %v, %v", found, err)
+			}
+			c := models.Captcha{UserID: 7, Kind: "math", Question: "2 + 3?", Options: []string{"4", "5", "6", "7"}, Answer: "5", Nonce: "k3x9", Attempts: 1}
+			if err := s.SaveCaptcha(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+			c.PassedAt, c.Attempts = time.Now().Truncate(time.Microsecond), 0
+			if err := s.SaveCaptcha(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+			got, found, err := s.GetCaptcha(ctx, 7)
+			if err != nil || !found {
+				t.Fatalf("GetCaptcha = %v, %v", found, err)
+			}
+			if got.UpdatedAt.IsZero() || !got.PassedAt.Equal(c.PassedAt) {
+				t.Errorf("times = %v / %v", got.PassedAt, got.UpdatedAt)
+			}
+			got.PassedAt, got.UpdatedAt = c.PassedAt, c.UpdatedAt
+			if !reflect.DeepEqual(got, c) {
+				t.Errorf("captcha = %+v, want %+v", got, c)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import "context"
+
+// GetSettings — все переопределённые админом настройки (key → value)
+func (r *Repository) GetSettings(ctx context.Context) (map[string]string, error) {
+	rows, err := r.DB.Query(ctx, `SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string]string)
+	for rows.Next() {
+		var k, v string
+		if err = rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, rows.Err()
+}
+
+func (r *Repository) SetSetting(ctx context.Context, key, value string) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO settings (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`, key, value)
+	return err
+}
+
+// DeleteSetting возвращает настройке значение по умолчанию
+func (r *Repository) DeleteSetting(ctx context.Context, key string) error {
+	_, err := r.DB.Exec(ctx, `DELETE FROM settings WHERE key=$1`, key)
+	return err
+}
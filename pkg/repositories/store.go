@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+)
+
+// Интерфейсы хранилища. Repository реализует их поверх Postgres,
+// Memory — в памяти для юнит-тестов.
+
+type Promotions interface {
+	CreatePromotion(ctx context.Context, name, value, imageURL string) error
+	UpdatePromotion(ctx context.Context, id int, name, value, imageURL string) error
+	DeletePromotion(ctx context.Context, id int) error
+	GetPromotions(ctx context.Context) ([]models.Promotion, error)
+	SetPromotionTranslation(ctx context.Context, id int, locale string, t models.PromotionText) error
+	DeletePromotionTranslation(ctx context.Context, id int, locale string) error
+}
+
+type Claims interface {
+	HasUserClaimed(ctx context.Context, userID int64) bool
+	ClaimPromotion(ctx context.Context, userID int64, once bool,
+		prize func(models.Promotion) []models.OutboxMessage) (models.Promotion, error)
+}
+
+// UserClaims — участие гостя для /user
+type UserClaims interface {
+	GetClaim(ctx context.Context, userID int64) (models.UserClaim, bool, error)
+	// ResetClaim снимает клейм; прежние сообщения с призом остаются в outbox
+	// для истории, но больше не мешают новому розыгрышу
+	ResetClaim(ctx context.Context, userID int64) (bool, error)
+}
+
+type Bans interface {
+	BanUser(ctx context.Context, b models.Ban) error
+	UnbanUser(ctx context.Context, userID int64) error
+	GetBan(ctx context.Context, userID int64) (models.Ban, bool, error)
+}
+
+// Captchas — задачи «не бот»; логика проверки — в services
+type Captchas interface {
+	GetCaptcha(ctx context.Context, userID int64) (models.Captcha, bool, error)
+	SaveCaptcha(ctx context.Context, c models.Captcha) error
+}
+
+type AdminStates interface {
+	SetAdminState(ctx context.Context, st models.AdminState) error
+	GetAdminState(ctx context.Context, userID int64) (models.AdminState, error)
+	ClearAdminState(ctx context.Context, userID int64) error
+}
+
+type Users interface {
+	// UpsertBotUser обновляет профиль и last_seen; StartPayload записывается
+	// только первый, флаг блокировки снимается. Возвращает язык из /language
+	UpsertBotUser(ctx context.Context, u models.BotUser) (language string, err error)
+	GetBotUser(ctx context.Context, userID int64) (models.BotUser, error)
+	// FindBotUser ищет по username без учёта регистра
+	FindBotUser(ctx context.Context, username string) (models.BotUser, error)
+	SetUserBlocked(ctx context.Context, userID int64, blocked bool) error
+	// GetUserLanguage — язык из /language; "" — не выбран
+	GetUserLanguage(ctx context.Context, userID int64) (string, error)
+	SetUserLanguage(ctx context.Context, userID int64, locale string) error
+}
+
+// ProcessedUpdates — отметки обработанных update_id для дедупликации
+type ProcessedUpdates interface {
+	IsUpdateProcessed(ctx context.Context, updateID int64) (bool, error)
+	MarkUpdateProcessed(ctx context.Context, updateID int64) error
+}
+
+// SettingsStore — настройки, переопределённые админом поверх значений из env
+type SettingsStore interface {
+	GetSettings(ctx context.Context) (map[string]string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	DeleteSetting(ctx context.Context, key string) error
+}
+
+// TemplatesStore — шаблоны сообщений, изменённые админом поверх встроенных
+type TemplatesStore interface {
+	// GetTemplates — locale → name → body
+	GetTemplates(ctx context.Context) (map[string]map[string]string, error)
+	SetTemplate(ctx context.Context, locale, name, body string) error
+	DeleteTemplate(ctx context.Context, locale, name string) error
+}
+
+type OutboxStore interface {
+	EnqueueOutbox(ctx context.Context, msgs ...models.OutboxMessage) error
+	// OutboxByKeyPrefix — сообщения с dedup_key, начинающимся с prefix, по порядку
+	OutboxByKeyPrefix(ctx context.Context, prefix string) ([]models.OutboxMessage, error)
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	MarkOutboxSent(ctx context.Context, id int64) error
+	FailOutbox(ctx context.Context, id int64, reason string, maxAttempts int, maxBackoff time.Duration) (bool, error)
+}
+
+type UpdateQueue interface {
+	EnqueueUpdate(ctx context.Context, updateID, shardKey int64, payload []byte) error
+	ClaimUpdates(ctx context.Context, limit int, lease time.Duration) ([]models.QueuedUpdate, error)
+	AckUpdate(ctx context.Context, updateID int64) error
+	FailUpdate(ctx context.Context, updateID int64, reason string, maxAttempts int, maxBackoff time.Duration) (bool, error)
+	ReleaseUpdate(ctx context.Context, updateID int64) error
+}
+
+// Store — всё, что нужно обработчикам и сервисам
+type Store interface {
+	Promotions
+	Claims
+	UserClaims
+	Bans
+	Captchas
+	AdminStates
+	Users
+	ProcessedUpdates
+	OutboxStore
+	SettingsStore
+	TemplatesStore
+}
+
+var (
+	_ Store       = (*Repository)(nil)
+	_ UpdateQueue = (*Repository)(nil)
+	_ Store       = (*Memory)(nil)
+	_ UpdateQueue = (*Memory)(nil)
+)
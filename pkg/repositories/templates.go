@@ -0,0 +1,38 @@
+package repositories
+
+import "context"
+
+// GetTemplates — шаблоны сообщений, изменённые админом (locale → name → body)
+func (r *Repository) GetTemplates(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := r.DB.Query(ctx, `SELECT locale, name, body FROM message_templates`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string]map[string]string)
+	for rows.Next() {
+		var locale, name, body string
+		if err = rows.Scan(&locale, &name, &body); err != nil {
+			return nil, err
+		}
+		if m[locale] == nil {
+			m[locale] = make(map[string]string)
+		}
+		m[locale][name] = body
+	}
+	return m, rows.Err()
+}
+
+func (r *Repository) SetTemplate(ctx context.Context, locale, name, body string) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO message_templates (locale, name, body) VALUES ($1, $2, $3)
+		ON CONFLICT (name, locale) DO UPDATE SET body = EXCLUDED.body, updated_at = now()`, locale, name, body)
+	return err
+}
+
+// DeleteTemplate возвращает шаблону встроенный текст
+func (r *Repository) DeleteTemplate(ctx context.Context, locale, name string) error {
+	_, err := r.DB.Exec(ctx, `DELETE FROM message_templates WHERE locale=$1 AND name=$2`, locale, name)
+	return err
+}
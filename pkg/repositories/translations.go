@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// querier — общее у пула и транзакции
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (r *Repository) SetPromotionTranslation(ctx context.Context, id int, locale string, t models.PromotionText) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO promotion_translations (promotion_id, locale, name, value) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (promotion_id, locale) DO UPDATE SET name = EXCLUDED.name, value = EXCLUDED.value`,
+		id, locale, t.Name, t.Value)
+	return err
+}
+
+func (r *Repository) DeletePromotionTranslation(ctx context.Context, id int, locale string) error {
+	_, err := r.DB.Exec(ctx, `DELETE FROM promotion_translations WHERE promotion_id=$1 AND locale=$2`, id, locale)
+	return err
+}
+
+// loadTranslations заполняет Texts у скидок из list
+func loadTranslations(ctx context.Context, q querier, list []models.Promotion) error {
+	if len(list) == 0 {
+		return nil
+	}
+	idx := make(map[int]int, len(list))
+	ids := make([]int32, 0, len(list))
+	for i, p := range list {
+		idx[p.ID] = i
+		ids = append(ids, int32(p.ID))
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT promotion_id, locale, name, value FROM promotion_translations
+		WHERE promotion_id = ANY($1)`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id     int
+			locale string
+			t      models.PromotionText
+		)
+		if err = rows.Scan(&id, &locale, &t.Name, &t.Value); err != nil {
+			return err
+		}
+		p := &list[idx[id]]
+		if p.Texts == nil {
+			p.Texts = make(map[string]models.PromotionText)
+		}
+		p.Texts[locale] = t
+	}
+	return rows.Err()
+}
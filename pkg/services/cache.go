@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cached — значение из БД с TTL. Перечитывает его один вызов, без блокировки:
+// остальные тем временем получают прежнее значение, а ждут загрузку только
+// до первой удачной. Если БД недоступна, значение остаётся прежним.
+type cached[T any] struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	val      T
+	loaded   bool
+	loadedAt time.Time
+	gen      uint64        // растёт на invalidate — загрузка, начатая раньше, не продлевает TTL
+	inflight chan struct{} // закрывается по окончании загрузки; nil — никто не грузит
+}
+
+// get отдаёт значение, при необходимости перечитывая его через load.
+// Ошибку load получает только тот вызов, который грузил.
+func (c *cached[T]) get(ctx context.Context, load func(context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	if c.loaded && time.Since(c.loadedAt) < c.ttl {
+		defer c.mu.Unlock()
+		return c.val, nil
+	}
+	if ch := c.inflight; ch != nil {
+		val, loaded := c.val, c.loaded
+		c.mu.Unlock()
+		if loaded {
+			return val, nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.val, nil
+	}
+	ch := make(chan struct{})
+	c.inflight = ch
+	gen := c.gen
+	c.mu.Unlock()
+
+	val, err := load(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight = nil
+	close(ch)
+	if err != nil {
+		return c.val, err
+	}
+	c.val, c.loaded = val, true
+	if gen == c.gen {
+		c.loadedAt = time.Now()
+	}
+	return val, nil
+}
+
+// invalidate — следующий get перечитает БД
+func (c *cached[T]) invalidate() {
+	c.mu.Lock()
+	c.gen++
+	c.loadedAt = time.Time{}
+	c.mu.Unlock()
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+)
+
+// Виды задач «не бот» (настройка captcha)
+const (
+	CaptchaEmoji = "emoji" // найти названный предмет среди эмодзи
+	CaptchaMath  = "math"  // сложить два числа
+)
+
+const (
+	CaptchaMaxAttempts = 3         // неверных ответов подряд до паузы
+	CaptchaLockout     = time.Hour // пауза после CaptchaMaxAttempts ошибок
+	captchaOptions     = 4         // кнопок с вариантами
+)
+
+var (
+	// ErrCaptchaLocked — ошибок слишком много, новая задача — после CaptchaLockout
+	ErrCaptchaLocked = errors.New("captcha locked")
+	// ErrCaptchaStale — кнопка от прежней задачи; попытка не засчитывается
+	ErrCaptchaStale = errors.New("captcha stale")
+)
+
+// Предметы для emoji-задачи; название — ключ каталога captcha.item.<name>
+var captchaItems = []struct{ name, emoji string }{
+	{"apple", "🍎"}, {"car", "🚗"}, {"dog", "🐶"}, {"cactus", "🌵"},
+	{"ball", "⚽"}, {"guitar", "🎸"}, {"pizza", "🍕"}, {"rocket", "🚀"},
+}
+
+// Challenge — задача для гостя перед розыгрышем. ok=false — проверка уже
+// пройдена. Каждый вызов выдаёт новую задачу, счётчик ошибок сохраняется.
+func (s *Service) Challenge(ctx context.Context, userID int64, kind, locale string) (models.Captcha, bool, error) {
+	c, found, err := s.Repo.GetCaptcha(ctx, userID)
+	if err != nil {
+		return models.Captcha{}, false, fmt.Errorf("get captcha: %w", err)
+	}
+	if found && !c.PassedAt.IsZero() {
+		return c, false, nil
+	}
+	if found && s.captchaLocked(c) {
+		return c, false, ErrCaptchaLocked
+	}
+	if found && c.Attempts >= CaptchaMaxAttempts {
+		c.Attempts = 0 // пауза прошла
+	}
+	c = newCaptcha(userID, kind, locale, c.Attempts)
+	if err = s.Repo.SaveCaptcha(ctx, c); err != nil {
+		return models.Captcha{}, false, fmt.Errorf("save captcha: %w", err)
+	}
+	return c, true, nil
+}
+
+// SolveCaptcha проверяет ответ на задачу nonce. passed — можно разыгрывать;
+// иначе next — следующая задача вида kind, а после CaptchaMaxAttempts
+// ошибок — ErrCaptchaLocked. Ответ на прежнюю задачу — ErrCaptchaStale.
+// Решённая задача остаётся пройденной: повтор апдейта после сбоя розыгрыша
+// снова получает passed и доходит до клейма.
+func (s *Service) SolveCaptcha(ctx context.Context, userID int64, nonce, answer, kind, locale string) (next models.Captcha, passed bool, err error) {
+	c, found, err := s.Repo.GetCaptcha(ctx, userID)
+	if err != nil {
+		return models.Captcha{}, false, fmt.Errorf("get captcha: %w", err)
+	}
+	switch {
+	case !found:
+		// кнопка от задачи, которой нет (например, после очистки БД) — выдаём новую
+		next, _, err = s.Challenge(ctx, userID, kind, locale)
+		return next, false, err
+	case nonce != c.Nonce:
+		return c, false, ErrCaptchaStale
+	case !c.PassedAt.IsZero():
+		return c, true, nil
+	case s.captchaLocked(c):
+		return c, false, ErrCaptchaLocked
+	}
+
+	if answer == c.Answer {
+		c.PassedAt, c.Attempts = s.policy.now(), 0
+		if err = s.Repo.SaveCaptcha(ctx, c); err != nil {
+			return models.Captcha{}, false, fmt.Errorf("save captcha: %w", err)
+		}
+		metrics.Inc("captcha_passed_total")
+		return c, true, nil
+	}
+
+	metrics.Inc("captcha_failed_total")
+	attempts := c.Attempts + 1
+	if attempts >= CaptchaMaxAttempts {
+		c.Attempts = attempts
+		if err = s.Repo.SaveCaptcha(ctx, c); err != nil {
+			return models.Captcha{}, false, fmt.Errorf("save captcha: %w", err)
+		}
+		return c, false, ErrCaptchaLocked
+	}
+	c = newCaptcha(userID, kind, locale, attempts)
+	if err = s.Repo.SaveCaptcha(ctx, c); err != nil {
+		return models.Captcha{}, false, fmt.Errorf("save captcha: %w", err)
+	}
+	return c, false, nil
+}
+
+func (s *Service) captchaLocked(c models.Captcha) bool {
+	return c.Attempts >= CaptchaMaxAttempts && s.policy.now().Sub(c.UpdatedAt) < CaptchaLockout
+}
+
+func newCaptcha(userID int64, kind, locale string, attempts int) models.Captcha {
+	c := models.Captcha{UserID: userID, Kind: kind, Attempts: attempts, Nonce: strconv.FormatUint(uint64(rand.Uint32()), 36)}
+	switch kind {
+	case CaptchaMath:
+		a, b := 1+rand.Intn(9), 1+rand.Intn(9)
+		sum := a + b
+		c.Question = i18n.T(locale, "captcha.math", "a", strconv.Itoa(a), "b", strconv.Itoa(b))
+		c.Answer = strconv.Itoa(sum)
+		seen := map[int]bool{sum: true}
+		c.Options = []string{c.Answer}
+		for len(c.Options) < captchaOptions {
+			if n := 2 + rand.Intn(17); !seen[n] {
+				seen[n] = true
+				c.Options = append(c.Options, strconv.Itoa(n))
+			}
+		}
+	default:
+		c.Kind = CaptchaEmoji
+		items := rand.Perm(len(captchaItems))[:captchaOptions]
+		target := captchaItems[items[0]]
+		c.Question = i18n.T(locale, "captcha.emoji", "item", i18n.T(locale, "captcha.item."+target.name))
+		c.Answer = target.emoji
+		for _, i := range items {
+			c.Options = append(c.Options, captchaItems[i].emoji)
+		}
+	}
+	rand.Shuffle(len(c.Options), func(i, j int) { c.Options[i], c.Options[j] = c.Options[j], c.Options[i] })
+	return c
+}
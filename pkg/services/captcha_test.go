@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+)
+
+func TestCaptcha(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(repositories.NewMemory())
+	now := time.Now()
+	s.policy.now = func() time.Time { return now }
+
+	c, ok, err := s.Challenge(ctx, 7, CaptchaMath, "ru")
+	if err != nil || !ok {
+		t.Fatalf("Challenge = %v, %v", ok, err)
+	}
+	seen := map[string]bool{}
+	for _, o := range c.Options {
+		seen[o] = true
+	}
+	if len(c.Options) != captchaOptions || len(seen) != captchaOptions || !seen[c.Answer] {
+		t.Fatalf("options = %v, answer %q", c.Options, c.Answer)
+	}
+
+	// неверные ответы: новая задача, затем пауза
+	for i := 1; i < CaptchaMaxAttempts; i++ {
+		next, passed, err := s.SolveCaptcha(ctx, 7, c.Nonce, "wrong", CaptchaEmoji, "en")
+		if err != nil || passed || next.Attempts != i || next.Kind != CaptchaEmoji || next.Nonce == c.Nonce {
+			t.Fatalf("wrong answer %d: %+v, %v, %v", i, next, passed, err)
+		}
+		// кнопки прежней задачи больше не принимаются и попытку не тратят
+		if _, _, err = s.SolveCaptcha(ctx, 7, c.Nonce, c.Answer, CaptchaEmoji, "en"); !errors.Is(err, ErrCaptchaStale) {
+			t.Fatalf("old task after wrong answer %d: err = %v, want ErrCaptchaStale", i, err)
+		}
+		c = next
+	}
+	if _, _, err = s.SolveCaptcha(ctx, 7, c.Nonce, "wrong", CaptchaEmoji, "en"); !errors.Is(err, ErrCaptchaLocked) {
+		t.Fatalf("last wrong answer: err = %v, want ErrCaptchaLocked", err)
+	}
+	if _, _, err = s.Challenge(ctx, 7, CaptchaEmoji, "en"); !errors.Is(err, ErrCaptchaLocked) {
+		t.Fatalf("Challenge while locked: err = %v", err)
+	}
+
+	now = now.Add(CaptchaLockout + time.Minute)
+	c, ok, err = s.Challenge(ctx, 7, CaptchaEmoji, "en")
+	if err != nil || !ok || c.Attempts != 0 {
+		t.Fatalf("Challenge after lockout = %+v, %v, %v", c, ok, err)
+	}
+	if _, passed, err := s.SolveCaptcha(ctx, 7, c.Nonce, c.Answer, CaptchaEmoji, "en"); err != nil || !passed {
+		t.Fatalf("right answer: passed = %v, err = %v", passed, err)
+	}
+	// повтор апдейта после сбоя розыгрыша: задача по-прежнему пройдена
+	if _, passed, err := s.SolveCaptcha(ctx, 7, c.Nonce, c.Answer, CaptchaEmoji, "en"); err != nil || !passed {
+		t.Fatalf("retried answer: passed = %v, err = %v", passed, err)
+	}
+	if _, _, err := s.SolveCaptcha(ctx, 7, "other", c.Answer, CaptchaEmoji, "en"); !errors.Is(err, ErrCaptchaStale) {
+		t.Fatalf("old task after passing: err = %v, want ErrCaptchaStale", err)
+	}
+	if _, ok, err = s.Challenge(ctx, 7, CaptchaEmoji, "en"); err != nil || ok {
+		t.Errorf("Challenge after passing = %v, %v, want no new task", ok, err)
+	}
+}
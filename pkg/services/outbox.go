@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	outboxBatch       = 8
+	outboxSendTimeout = 20 * time.Second // на одно сообщение, с повторами Sender
+	outboxMarkTimeout = 5 * time.Second  // на отметку sent/failed после отправки
+	// Аренда переживает худший случай для всей пачки, иначе другая реплика
+	// заберёт ещё не отправленные строки и приз уйдёт дважды
+	outboxLease       = outboxBatch*(outboxSendTimeout+outboxMarkTimeout) + 30*time.Second
+	outboxPollEvery   = 500 * time.Millisecond
+	outboxMaxAttempts = 12
+	outboxMaxBackoff  = 10 * time.Minute
+)
+
+// Outbox доставляет сообщения из таблицы outbox: с задержкой send_after,
+// по порядку внутри чата, с повторами. Отправка at-least-once: если процесс
+// упадёт между отправкой и отметкой sent, сообщение уйдёт ещё раз.
+type Outbox struct {
+	repo   repositories.OutboxStore
+	sender Messenger
+	log    *slog.Logger
+}
+
+func NewOutbox(repo repositories.OutboxStore, sender Messenger, logger *slog.Logger) *Outbox {
+	return &Outbox{repo: repo, sender: sender, log: logger.With("component", "outbox")}
+}
+
+// Run доставляет сообщения, пока ctx не отменён; начатая отправка доводится до конца
+func (o *Outbox) Run(ctx context.Context) {
+	t := time.NewTicker(outboxPollEvery)
+	defer t.Stop()
+
+	for {
+		n, err := o.deliverBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			o.log.Error("claim outbox", "err", err)
+		}
+		if n == outboxBatch && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (o *Outbox) deliverBatch(ctx context.Context) (int, error) {
+	items, err := o.repo.ClaimOutbox(ctx, outboxBatch, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range items {
+		// отправку не обрываем на остановке — иначе приз уйдёт повторно
+		o.deliver(context.WithoutCancel(ctx), m)
+	}
+	return len(items), nil
+}
+
+func (o *Outbox) deliver(ctx context.Context, m models.OutboxMessage) {
+	sendCtx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+	defer cancel()
+	l := o.log.With("outbox_id", m.ID, "chat_id", m.ChatID, "attempt", m.Attempts)
+
+	msg, err := outboxChattable(m)
+	if err == nil {
+		_, err = o.sender.Send(sendCtx, msg)
+	}
+	if err != nil && m.Kind == OutboxPhoto && imageRejected(err) {
+		// битая картинка скидки не должна лишать гостя приза — шлём текстом
+		l.Warn("outbox photo rejected, sending as text", "image_url", m.ImageURL, "err", err)
+		metrics.Inc("outbox_photo_fallbacks_total")
+		m.Kind = OutboxText
+		if msg, err = outboxChattable(m); err == nil {
+			_, err = o.sender.Send(sendCtx, msg)
+		}
+	}
+
+	// отметка — в своём таймауте: отправка могла съесть весь sendCtx
+	ctx, cancelMark := context.WithTimeout(ctx, outboxMarkTimeout)
+	defer cancelMark()
+	if err == nil {
+		metrics.Inc("outbox_sent_total")
+		if err = o.repo.MarkOutboxSent(ctx, m.ID); err != nil {
+			l.Error("mark outbox sent", "err", err)
+		}
+		return
+	}
+
+	metrics.Inc("outbox_errors_total")
+	maxAttempts := outboxMaxAttempts
+	if IsPermanent(err) {
+		maxAttempts = 0 // бот заблокирован / чат не найден — повторять бессмысленно
+	}
+	failed, ferr := o.repo.FailOutbox(ctx, m.ID, err.Error(), maxAttempts, outboxMaxBackoff)
+	if ferr != nil {
+		l.Error("fail outbox", "err", ferr)
+		return
+	}
+	if failed {
+		metrics.Inc("outbox_failed_total")
+		l.Error("outbox message given up", "err", err)
+		return
+	}
+	l.Warn("outbox send failed, will retry", "err", err)
+}
+
+// imageRejected — Telegram отверг сам файл (400: не скачался, не картинка),
+// а не чат: 403 и «chat not found» текстом тоже не исправить
+func imageRejected(err error) bool {
+	var se *SendError
+	return errors.As(err, &se) && se.Permanent && se.Code == 400 &&
+		!strings.Contains(strings.ToLower(se.Err.Error()), "chat not found")
+}
+
+func outboxChattable(m models.OutboxMessage) (tgbotapi.Chattable, error) {
+	var markup any
+	if len(m.ReplyMarkup) > 0 {
+		var mk tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal(m.ReplyMarkup, &mk); err != nil {
+			return nil, fmt.Errorf("reply markup: %w", err)
+		}
+		markup = mk
+	}
+
+	switch m.Kind {
+	case OutboxPhoto:
+		photo := tgbotapi.NewPhoto(m.ChatID, tgbotapi.FileURL(m.ImageURL))
+		photo.Caption = m.Text
+		photo.ParseMode = m.ParseMode
+		photo.ReplyMarkup = markup
+		return photo, nil
+	case OutboxText:
+		msg := tgbotapi.NewMessage(m.ChatID, m.Text)
+		msg.ParseMode = m.ParseMode
+		msg.ReplyMarkup = markup
+		return msg, nil
+	}
+	return nil, fmt.Errorf("unknown outbox kind %q", m.Kind)
+}
+
+const (
+	OutboxText  = "text"
+	OutboxPhoto = "photo"
+)
+
+// OutboxMarkup сериализует клавиатуру для поля ReplyMarkup
+func OutboxMarkup(mk tgbotapi.InlineKeyboardMarkup) []byte {
+	b, _ := json.Marshal(mk)
+	return b
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+)
+
+func TestOutboxPhotoFallsBackToText(t *testing.T) {
+	s, srv := newTestSender(t)
+	repo := repositories.NewMemory()
+	ctx := context.Background()
+	err := repo.EnqueueOutbox(ctx, models.OutboxMessage{
+		DedupKey: "claim:42:1:prize", ChatID: 42, Kind: OutboxPhoto, Text: "-10%",
+		ImageURL: "https://example.com/broken.jpg", SendAfter: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.FailNext("sendPhoto", 400, "Bad Request: wrong file identifier/HTTP URL specified", 0)
+
+	o := NewOutbox(repo, s, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if n, err := o.deliverBatch(ctx); err != nil || n != 1 {
+		t.Fatalf("deliverBatch = %d, %v", n, err)
+	}
+	msgs := srv.Calls("sendMessage")
+	if len(msgs) != 1 || msgs[0].Params["text"] != "-10%" {
+		t.Fatalf("sendMessage calls = %+v, want the prize as text", msgs)
+	}
+	if got, _ := repo.OutboxByKeyPrefix(ctx, "claim:42:"); len(got) != 1 || got[0].Status != "sent" {
+		t.Errorf("outbox = %+v, want sent", got)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/ratelimit"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+)
+
+// Причины отказа в розыгрыше — в логах и метрике draw_denied_total:<причина>
+const (
+	DenyFlood      = "flood"       // слишком много попыток
+	DenyNewAccount = "new_account" // ID больше max_user_id
+	DenyNoUsername = "no_username"
+	DenyNotPremium = "not_premium"
+	DenyBanned     = "banned"
+	DenyTooEarly   = "too_early" // /start нажат позже чем min_start_age назад
+)
+
+// ErrDrawDenied — розыгрыш запрещён проверками; причина — в *DeniedError
+var ErrDrawDenied = errors.New("draw denied")
+
+type DeniedError struct {
+	Reason string
+	Repeat bool // о таком отказе гостю уже ответили в этом окне — можно промолчать
+}
+
+func (e *DeniedError) Error() string        { return "draw denied: " + e.Reason }
+func (e *DeniedError) Is(target error) bool { return target == ErrDrawDenied }
+
+// DenyReason — причина отказа из ошибки CheckDraw ("" — не отказ)
+func DenyReason(err error) string {
+	var d *DeniedError
+	if errors.As(err, &d) {
+		return d.Reason
+	}
+	return ""
+}
+
+// DrawRequest — кто пытается участвовать и с какими настройками
+type DrawRequest struct {
+	UserID    int64
+	Username  string
+	IsPremium bool
+	Settings  models.Settings
+}
+
+// drawCheck — одна проверка: "" — пропустить дальше, иначе причина отказа
+type drawCheck func(ctx context.Context, req DrawRequest) (string, error)
+
+// drawPolicy — проверки перед розыгрышем; флуд-лимит держится в памяти
+// процесса и пересоздаётся, когда админ меняет draw_flood
+type drawPolicy struct {
+	repo repositories.Store
+	now  func() time.Time
+
+	mu          sync.Mutex
+	flood       *ratelimit.Keyed
+	floodNotice *ratelimit.Keyed // один ответ об отказе на окно draw_flood
+	floodRate   models.Rate
+}
+
+// checks — дешёвые проверки по данным Telegram идут раньше запросов в БД
+func (p *drawPolicy) checks() []drawCheck {
+	return []drawCheck{p.checkAccount, p.checkBan, p.checkStartAge}
+}
+
+// checkFlood: denied — попыток больше draw_flood, repeat — об этом уже сообщали
+func (p *drawPolicy) checkFlood(req DrawRequest) (denied, repeat bool) {
+	r := req.Settings.DrawFlood
+	if r.N <= 0 || r.Per <= 0 {
+		return false, false
+	}
+	p.mu.Lock()
+	if p.flood == nil || p.floodRate != r {
+		p.flood = ratelimit.NewKeyed(r.Per, func(int64) *rate.Limiter {
+			return rate.NewLimiter(rate.Every(r.Per/time.Duration(r.N)), r.N)
+		})
+		p.floodNotice = ratelimit.NewKeyed(r.Per, func(int64) *rate.Limiter {
+			return rate.NewLimiter(rate.Every(r.Per), 1)
+		})
+		p.floodRate = r
+	}
+	limits, notice := p.flood, p.floodNotice
+	p.mu.Unlock()
+	if limits.Allow(req.UserID) {
+		return false, false
+	}
+	return true, !notice.Allow(req.UserID)
+}
+
+func (p *drawPolicy) checkAccount(_ context.Context, req DrawRequest) (string, error) {
+	st := req.Settings
+	switch {
+	case st.MaxUserID > 0 && req.UserID > st.MaxUserID:
+		return DenyNewAccount, nil
+	case st.RequireUsername && req.Username == "":
+		return DenyNoUsername, nil
+	case st.RequirePremium && !req.IsPremium:
+		return DenyNotPremium, nil
+	}
+	return "", nil
+}
+
+func (p *drawPolicy) checkBan(ctx context.Context, req DrawRequest) (string, error) {
+	_, banned, err := p.repo.GetBan(ctx, req.UserID)
+	if err != nil {
+		return "", fmt.Errorf("check ban: %w", err)
+	}
+	if banned {
+		return DenyBanned, nil
+	}
+	return "", nil
+}
+
+// checkStartAge — first_seen_at пишет Profile при первом обращении к боту;
+// профиля нет — значит, гость пришёл только что
+func (p *drawPolicy) checkStartAge(ctx context.Context, req DrawRequest) (string, error) {
+	if req.Settings.MinStartAge <= 0 {
+		return "", nil
+	}
+	u, err := p.repo.GetBotUser(ctx, req.UserID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		return DenyTooEarly, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get profile: %w", err)
+	}
+	if p.now().Sub(u.FirstSeen) < req.Settings.MinStartAge {
+		return DenyTooEarly, nil
+	}
+	return "", nil
+}
+
+// CheckDraw прогоняет проверки перед розыгрышем; отказ — *DeniedError
+// (errors.Is(err, ErrDrawDenied)), остальные ошибки — сбой хранилища.
+// Лимит попыток — отдельно, в CheckFlood.
+func (s *Service) CheckDraw(ctx context.Context, req DrawRequest) error {
+	for _, check := range s.policy.checks() {
+		reason, err := check(ctx, req)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			metrics.Inc("draw_denied_total:" + reason)
+			return &DeniedError{Reason: reason}
+		}
+	}
+	return nil
+}
+
+// CheckFlood считает попытку против draw_flood. Вызывается, когда попытка
+// дошла до клейма: отказы проверок и «Проверить подписку» лимит не тратят.
+func (s *Service) CheckFlood(req DrawRequest) error {
+	denied, repeat := s.policy.checkFlood(req)
+	if !denied {
+		return nil
+	}
+	metrics.Inc("draw_denied_total:" + DenyFlood)
+	return &DeniedError{Reason: DenyFlood, Repeat: repeat}
+}
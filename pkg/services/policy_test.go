@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+)
+
+func TestCheckDraw(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewMemory()
+	s := NewService(repo)
+	now := time.Now()
+	s.policy.now = func() time.Time { return now }
+
+	_, _ = repo.UpsertBotUser(ctx, models.BotUser{UserID: 7, ChatID: 7, Username: "ann"})
+	_ = repo.BanUser(ctx, models.Ban{UserID: 8, BannedBy: 1})
+
+	strict := models.Settings{MaxUserID: 100, RequireUsername: true, RequirePremium: true, MinStartAge: time.Hour}
+	for _, tc := range []struct {
+		name string
+		req  DrawRequest
+		want string
+	}{
+		{"allowed by default", DrawRequest{UserID: 7}, ""},
+		{"banned", DrawRequest{UserID: 8}, DenyBanned},
+		{"new account", DrawRequest{UserID: 101, Username: "x", IsPremium: true, Settings: strict}, DenyNewAccount},
+		{"no username", DrawRequest{UserID: 7, IsPremium: true, Settings: strict}, DenyNoUsername},
+		{"not premium", DrawRequest{UserID: 7, Username: "ann", Settings: strict}, DenyNotPremium},
+		{"account checks before ban", DrawRequest{UserID: 8, Settings: strict}, DenyNoUsername},
+		{"too early", DrawRequest{UserID: 7, Username: "ann", IsPremium: true, Settings: strict}, DenyTooEarly},
+		{"no profile", DrawRequest{UserID: 9, Username: "bob", IsPremium: true, Settings: strict}, DenyTooEarly},
+	} {
+		err := s.CheckDraw(ctx, tc.req)
+		if got := DenyReason(err); got != tc.want {
+			t.Errorf("%s: reason = %q (err %v), want %q", tc.name, got, err, tc.want)
+		}
+		if tc.want != "" && !errors.Is(err, ErrDrawDenied) {
+			t.Errorf("%s: err = %v, want ErrDrawDenied", tc.name, err)
+		}
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := s.CheckDraw(ctx, DrawRequest{UserID: 7, Username: "ann", IsPremium: true, Settings: strict}); err != nil {
+		t.Errorf("after min_start_age: %v", err)
+	}
+}
+
+func TestCheckFlood(t *testing.T) {
+	s := NewService(repositories.NewMemory())
+	st := models.Settings{DrawFlood: models.Rate{N: 2, Per: time.Hour}}
+
+	for i := 0; i < 2; i++ {
+		if err := s.CheckFlood(DrawRequest{UserID: 7, Settings: st}); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	// первый отказ в окне сообщается гостю, следующие — нет
+	var d *DeniedError
+	if err := s.CheckFlood(DrawRequest{UserID: 7, Settings: st}); !errors.As(err, &d) || d.Reason != DenyFlood || d.Repeat {
+		t.Errorf("third attempt: err = %v, want a first flood refusal", err)
+	}
+	if err := s.CheckFlood(DrawRequest{UserID: 7, Settings: st}); !errors.As(err, &d) || !d.Repeat {
+		t.Errorf("fourth attempt: err = %v, want a repeated flood refusal", err)
+	}
+	if err := s.CheckFlood(DrawRequest{UserID: 8, Settings: st}); err != nil {
+		t.Errorf("another user: %v", err)
+	}
+	if err := s.CheckDraw(context.Background(), DrawRequest{UserID: 7, Settings: st}); err != nil {
+		t.Errorf("CheckDraw counts flood: %v", err)
+	}
+
+	// новый лимит от админа начинает счёт заново
+	st.DrawFlood.N = 3
+	if err := s.CheckFlood(DrawRequest{UserID: 7, Settings: st}); err != nil {
+		t.Errorf("after limit change: %v", err)
+	}
+	// по умолчанию лимита нет
+	for i := 0; i < 10; i++ {
+		if err := s.CheckFlood(DrawRequest{UserID: 9}); err != nil {
+			t.Fatalf("no limit, attempt %d: %v", i+1, err)
+		}
+	}
+}
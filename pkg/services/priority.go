@@ -0,0 +1,187 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Priority — класс исходящего трафика
+type Priority int
+
+const (
+	Interactive Priority = iota // ответы на действия пользователя
+	Bulk                        // рассылки, фоновые задачи
+)
+
+func (p Priority) String() string {
+	if p == Bulk {
+		return "bulk"
+	}
+	return "interactive"
+}
+
+type priorityKey struct{}
+
+// WithPriority помечает вызовы Telegram в ctx классом трафика
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom — класс трафика из ctx (по умолчанию Interactive)
+func PriorityFrom(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// errLanesClosed — Sender закрыт, новые вызовы не ждут токена
+var errLanesClosed = errors.New("sender closed")
+
+// lanes раздаёт токены глобального лимитера по классам: интерактивные идут
+// первыми, но при конкуренции bulk гарантированно получает долю bulkShare
+type lanes struct {
+	lim       *rate.Limiter
+	bulkShare float64
+
+	mu     sync.Mutex
+	queues [2]*list.List // ожидающие по классам, элементы — chan struct{}
+	credit float64       // накопленная доля bulk
+	kick   chan struct{}
+
+	done chan struct{}
+	stop sync.Once
+}
+
+func newLanes(lim *rate.Limiter, bulkShare float64) *lanes {
+	l := &lanes{
+		lim:       lim,
+		bulkShare: bulkShare,
+		queues:    [2]*list.List{list.New(), list.New()},
+		kick:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// acquire ждёт токен для класса p
+func (l *lanes) acquire(ctx context.Context, p Priority) error {
+	ready := make(chan struct{})
+	l.mu.Lock()
+	el := l.queues[p].PushBack(ready)
+	l.mu.Unlock()
+
+	select {
+	case l.kick <- struct{}{}:
+	default:
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-l.done:
+		err = errLanesClosed
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		return nil // токен уже выдан — пользуемся
+	default:
+	}
+	l.queues[p].Remove(el)
+	return err
+}
+
+// close останавливает планировщик; ждущие и новые вызовы получают errLanesClosed
+func (l *lanes) close() {
+	l.stop.Do(func() { close(l.done) })
+}
+
+func (l *lanes) run() {
+	for {
+		if l.pending() == 0 {
+			select {
+			case <-l.kick:
+				continue
+			case <-l.done:
+				return
+			}
+		}
+		wait := l.grant()
+		if wait <= 0 {
+			select {
+			case <-l.done:
+				return
+			default:
+				continue
+			}
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-l.done:
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (l *lanes) pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queues[Interactive].Len() + l.queues[Bulk].Len()
+}
+
+// grant отдаёт токен: bulk — когда набрал долю или интерактивных нет.
+// Токен берётся у лимитера только под ожидающего; если его пока нет,
+// резерв отменяется и возвращается, сколько ждать следующего
+func (l *lanes) grant() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inter, bulk := l.queues[Interactive], l.queues[Bulk]
+	if inter.Len()+bulk.Len() == 0 {
+		return 0 // ожидающие ушли по ctx — токен остаётся у лимитера
+	}
+	r := l.lim.Reserve()
+	if wait := r.Delay(); wait > 0 {
+		r.Cancel()
+		return wait
+	}
+
+	var q *list.List
+	switch {
+	case inter.Len() > 0 && bulk.Len() > 0:
+		l.credit += l.bulkShare
+		if l.credit >= 1 {
+			l.credit--
+			q = bulk
+		} else {
+			q = inter
+		}
+	case inter.Len() > 0:
+		q = inter
+	default:
+		q = bulk
+	}
+	close(q.Remove(q.Front()).(chan struct{}))
+	return 0
+}
+
+// lengths — сколько вызовов ждёт токена в каждом классе
+func (l *lanes) lengths() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return map[string]int{
+		Interactive.String(): l.queues[Interactive].Len(),
+		Bulk.String():        l.queues[Bulk].Len(),
+	}
+}
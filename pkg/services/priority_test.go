@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ожидающий ушёл по ctx — его токен достаётся следующему, а не пропадает
+func TestLanesKeepTokenOfCancelledWaiter(t *testing.T) {
+	l := newLanes(rate.NewLimiter(rate.Every(100*time.Millisecond), 1), 0.2)
+	defer l.close()
+	ctx := context.Background()
+
+	if err := l.acquire(ctx, Interactive); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(short, Interactive); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire = %v, want deadline exceeded", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	start := time.Now()
+	if err := l.acquire(ctx, Interactive); err != nil {
+		t.Fatalf("acquire after refill: %v", err)
+	}
+	if d := time.Since(start); d > 30*time.Millisecond {
+		t.Errorf("waited %v for a token that was already refilled", d)
+	}
+}
+
+func TestLanesClose(t *testing.T) {
+	l := newLanes(rate.NewLimiter(rate.Every(time.Hour), 1), 0.2)
+	if err := l.acquire(context.Background(), Bulk); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- l.acquire(context.Background(), Bulk) }()
+	time.Sleep(20 * time.Millisecond)
+	l.close()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errLanesClosed) {
+			t.Errorf("waiter got %v, want errLanesClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not released by close")
+	}
+	if err := l.acquire(context.Background(), Interactive); !errors.Is(err, errLanesClosed) {
+		t.Errorf("acquire after close = %v, want errLanesClosed", err)
+	}
+}
@@ -2,33 +2,339 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/Redarek/go-tg-bot-rest/pkg/logging"
+	"github.com/Redarek/go-tg-bot-rest/pkg/metrics"
+	"github.com/Redarek/go-tg-bot-rest/pkg/ratelimit"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"golang.org/x/time/rate"
 )
 
+// Лимиты Telegram на один чат поверх глобального
+var (
+	privateChatRate  = rate.Limit(1) // ~1 сообщение в секунду в личку
+	privateChatBurst = 3
+	groupChatRate    = rate.Limit(20.0 / 60) // 20 сообщений в минуту в группу
+	groupChatBurst   = 5
+)
+
+const (
+	chatLimiterIdle = 10 * time.Minute
+
+	sendMaxAttempts = 4
+	sendBaseBackoff = 500 * time.Millisecond
+	sendMaxBackoff  = 10 * time.Second
+)
+
+// SendError — итог вызова Telegram после всех попыток
+type SendError struct {
+	Op        string
+	ChatID    int64 // чат отправки; 0 — вызов не про конкретный чат
+	Code      int   // код ответа Telegram, 0 — сетевая/прочая ошибка
+	Permanent bool  // повтор не поможет: бот заблокирован, чат не найден, …
+	Attempts  int
+	Err       error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("telegram %s failed after %d attempt(s): %v", e.Op, e.Attempts, e.Err)
+}
+
+func (e *SendError) Unwrap() error { return e.Err }
+
+// IsPermanent — ошибка не исчезнет при повторе (403 blocked, 400 chat not found, …)
+func IsPermanent(err error) bool {
+	var se *SendError
+	return errors.As(err, &se) && se.Permanent
+}
+
+// Messenger — все исходящие вызовы Telegram. Реализация (Sender) лимитирует,
+// повторяет и считает каждый вызов; в тестах подменяется фейком.
+type Messenger interface {
+	Send(ctx context.Context, msg tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	EditMessage(ctx context.Context, edit tgbotapi.Chattable) (tgbotapi.Message, error)
+	DeleteMessage(ctx context.Context, chatID int64, messageID int) error
+	SendMediaGroup(ctx context.Context, cfg tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error)
+	AnswerCallback(ctx context.Context, callbackID, text string) error
+	GetChatMember(ctx context.Context, cfg tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error)
+	GetMe(ctx context.Context) (tgbotapi.User, error)
+}
+
+var _ Messenger = (*Sender)(nil)
+
+// BotClient — узкий интерфейс к Bot API, которым пользуется Sender.
+// *tgbotapi.BotAPI реализует его как есть; в тестах он смотрит на fakebot.
+type BotClient interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	SendMediaGroup(cfg tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error)
+	GetChatMember(cfg tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error)
+	GetMe() (tgbotapi.User, error)
+}
+
+var _ BotClient = (*tgbotapi.BotAPI)(nil)
+
 type Sender struct {
-	bot *tgbotapi.BotAPI
-	lim *rate.Limiter
+	bot   BotClient
+	lanes *lanes           // глобальный лимит с приоритетами
+	chats *ratelimit.Keyed // лимиты на чат, простаивающие вытесняются
+	log   *slog.Logger
+}
+
+// NewSender: lim — глобальный лимит Telegram, bulkShare — доля токенов,
+// гарантированная фоновому трафику, когда интерактивный тоже ждёт
+func NewSender(bot BotClient, lim *rate.Limiter, bulkShare float64, logger *slog.Logger) *Sender {
+	return &Sender{
+		bot:   bot,
+		lanes: newLanes(lim, bulkShare),
+		chats: ratelimit.NewKeyed(chatLimiterIdle, newChatLimiter),
+		log:   logger,
+	}
 }
 
-func NewSender(bot *tgbotapi.BotAPI, lim *rate.Limiter) *Sender {
-	return &Sender{bot: bot, lim: lim}
+// Close останавливает планировщик глобального лимита; вызовы после него
+// сразу возвращают ошибку
+func (s *Sender) Close() { s.lanes.close() }
+
+func newChatLimiter(chatID int64) *rate.Limiter {
+	if chatID < 0 { // группы и каналы
+		return rate.NewLimiter(groupChatRate, groupChatBurst)
+	}
+	return rate.NewLimiter(privateChatRate, privateChatBurst)
 }
 
-// Глобальный лимит на любой исходящий вызов
+// Глобальный лимит на любой исходящий вызов; класс трафика берётся из ctx
+// (WithPriority), интерактивные вызовы обслуживаются раньше bulk
 func (s *Sender) Wait(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	p := PriorityFrom(ctx)
+	timeout := 5 * time.Second
+	if p == Bulk {
+		timeout = time.Minute // bulk может подождать, пока идут ответы пользователям
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	return s.lim.Wait(ctx)
+	return s.lanes.acquire(ctx, p)
 }
 
-// Отправка сообщений (Chattable)
+// QueueLengths — сколько вызовов ждёт глобального лимита по классам
+func (s *Sender) QueueLengths() map[string]int { return s.lanes.lengths() }
+
+// WaitChat — лимит на конкретный чат; ждёт не дольше дедлайна ctx
+func (s *Sender) WaitChat(ctx context.Context, chatID int64) error {
+	if chatID == 0 {
+		return nil
+	}
+	return s.chats.Get(chatID).Wait(ctx)
+}
+
+// Отправка сообщений (Chattable): лимит чата, затем глобальный
 func (s *Sender) Send(ctx context.Context, msg tgbotapi.Chattable) (tgbotapi.Message, error) {
-	if err := s.Wait(ctx); err != nil {
-		var empty tgbotapi.Message
-		return empty, err
+	var m tgbotapi.Message
+	err := s.do(ctx, opName(msg), chatIDOf(msg), func() error {
+		var err error
+		m, err = s.bot.Send(msg)
+		return err
+	})
+	return m, err
+}
+
+// Request — вызовы без сообщения в ответе (answerCallbackQuery, setMyCommands, …)
+func (s *Sender) Request(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	err := s.do(ctx, opName(c), 0, func() error {
+		var err error
+		resp, err = s.bot.Request(c)
+		return err
+	})
+	return resp, err
+}
+
+// EditMessage — editMessageText/Caption/ReplyMarkup; правка — не новое сообщение,
+// поэтому лимит чата не применяется
+func (s *Sender) EditMessage(ctx context.Context, edit tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var m tgbotapi.Message
+	err := s.do(ctx, opName(edit), 0, func() error {
+		var err error
+		m, err = s.bot.Send(edit)
+		return err
+	})
+	return m, err
+}
+
+func (s *Sender) DeleteMessage(ctx context.Context, chatID int64, messageID int) error {
+	_, err := s.Request(ctx, tgbotapi.NewDeleteMessage(chatID, messageID))
+	return err
+}
+
+func (s *Sender) SendMediaGroup(ctx context.Context, cfg tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error) {
+	var ms []tgbotapi.Message
+	err := s.do(ctx, opName(cfg), cfg.ChatID, func() error {
+		var err error
+		ms, err = s.bot.SendMediaGroup(cfg)
+		return err
+	})
+	return ms, err
+}
+
+// AnswerCallback убирает "часики" на inline-кнопке
+func (s *Sender) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	_, err := s.Request(ctx, tgbotapi.NewCallback(callbackID, text))
+	return err
+}
+
+func (s *Sender) GetChatMember(ctx context.Context, cfg tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error) {
+	var member tgbotapi.ChatMember
+	err := s.do(ctx, opName(cfg), 0, func() error {
+		var err error
+		member, err = s.bot.GetChatMember(cfg)
+		return err
+	})
+	return member, err
+}
+
+func (s *Sender) GetMe(ctx context.Context) (tgbotapi.User, error) {
+	var u tgbotapi.User
+	err := s.do(ctx, "GetMe", 0, func() error {
+		var err error
+		u, err = s.bot.GetMe()
+		return err
+	})
+	return u, err
+}
+
+// do выполняет вызов с лимитом и повторами: 429 ждёт retry_after,
+// 5xx и сетевые ошибки — экспоненциальный backoff, 4xx сразу возвращаются.
+// chatID — чей лимит чата тратит каждая попытка (0 — без лимита чата)
+func (s *Sender) do(ctx context.Context, op string, chatID int64, call func() error) error {
+	l := logging.FromContext(ctx, s.log)
+	backoff := sendBaseBackoff
+
+	for attempt := 1; ; attempt++ {
+		// каждая попытка — новый запрос к Telegram, поэтому лимит чата берётся заново
+		if err := s.WaitChat(ctx, chatID); err != nil {
+			l.Warn("chat rate limiter wait failed", "op", op, "err", err)
+			return &SendError{Op: op, ChatID: chatID, Attempts: attempt - 1, Err: err}
+		}
+		if err := s.Wait(ctx); err != nil {
+			l.Warn("rate limiter wait failed", "op", op, "err", err)
+			return &SendError{Op: op, ChatID: chatID, Attempts: attempt - 1, Err: err}
+		}
+
+		metrics.Inc("telegram_requests_total")
+		metrics.Inc("telegram_requests_total:" + op)
+		start := time.Now()
+		err := call()
+		metrics.Add("telegram_latency_ms_total:"+op, time.Since(start).Milliseconds())
+		if err == nil {
+			return nil
+		}
+		metrics.Inc("telegram_errors_total")
+		metrics.Inc("telegram_errors_total:" + op)
+
+		code, retryAfter, permanent := classify(err)
+		if permanent || attempt >= sendMaxAttempts || ctx.Err() != nil {
+			l.Debug("telegram call failed", "op", op, "code", code, "attempt", attempt, "permanent", permanent, "err", err)
+			return &SendError{Op: op, ChatID: chatID, Code: code, Permanent: permanent, Attempts: attempt, Err: err}
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			metrics.Inc("telegram_flood_waits_total")
+			wait = retryAfter
+		} else {
+			backoff = min(backoff*2, sendMaxBackoff)
+		}
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) < wait {
+			// не успеем дождаться — честно отдаём ошибку сейчас
+			return &SendError{Op: op, ChatID: chatID, Code: code, Attempts: attempt, Err: err}
+		}
+
+		metrics.Inc("telegram_retries_total")
+		l.Warn("telegram call failed, retrying", "op", op, "code", code, "attempt", attempt, "wait", wait, "err", err)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return &SendError{Op: op, ChatID: chatID, Code: code, Attempts: attempt, Err: err}
+		case <-t.C:
+		}
+	}
+}
+
+// opName — имя вызова для логов и ошибок: тип конфига (MessageConfig, PhotoConfig, …)
+func opName(c tgbotapi.Chattable) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", c), "tgbotapi.")
+}
+
+// chatIDOf — чат, в который уходит сообщение (0 — неизвестен, лимит чата не применяется)
+func chatIDOf(c tgbotapi.Chattable) int64 {
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case tgbotapi.PhotoConfig:
+		return m.ChatID
+	case tgbotapi.DiceConfig:
+		return m.ChatID
+	case tgbotapi.DocumentConfig:
+		return m.ChatID
+	case tgbotapi.VideoConfig:
+		return m.ChatID
+	case tgbotapi.AnimationConfig:
+		return m.ChatID
+	case tgbotapi.StickerConfig:
+		return m.ChatID
+	case tgbotapi.CopyMessageConfig:
+		return m.ChatID
+	case tgbotapi.ForwardConfig:
+		return m.ChatID
+	}
+	return 0
+}
+
+func codeFromDescription(desc string) int {
+	switch {
+	case strings.HasPrefix(desc, "Bad Request"):
+		return 400
+	case strings.HasPrefix(desc, "Unauthorized"):
+		return 401
+	case strings.HasPrefix(desc, "Forbidden"):
+		return 403
+	case strings.HasPrefix(desc, "Not Found"):
+		return 404
+	case strings.HasPrefix(desc, "Too Many Requests"):
+		return 429
+	}
+	return 0
+}
+
+// classify разбирает ошибку Telegram: код, сколько ждать при 429 и стоит ли повторять
+func classify(err error) (code int, retryAfter time.Duration, permanent bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		// сеть, таймаут, невалидный ответ (HTML от прокси на 5xx) — временное
+		return 0, 0, false
+	}
+	code = apiErr.Code
+	if code == 0 {
+		// загрузка файлов (multipart) в tgbotapi теряет error_code — восстанавливаем по описанию
+		code = codeFromDescription(apiErr.Message)
+	}
+	switch {
+	case apiErr.RetryAfter > 0:
+		return code, time.Duration(apiErr.RetryAfter) * time.Second, false
+	case code == 429:
+		return code, time.Second, false
+	case code >= 500:
+		return code, 0, false
+	case code >= 400:
+		// 400 chat not found / bad request, 401, 403 blocked by user, 404
+		return code, 0, true
 	}
-	return s.bot.Send(msg)
+	return code, 0, false
 }
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/fakebot"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/time/rate"
+)
+
+func newTestSender(t *testing.T) (*Sender, *fakebot.Server) {
+	t.Helper()
+	srv := fakebot.New()
+	t.Cleanup(srv.Close)
+	bot, err := srv.NewBot()
+	if err != nil {
+		t.Fatalf("new bot: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewSender(bot, rate.NewLimiter(rate.Inf, 1), 0.2, logger)
+	t.Cleanup(s.Close)
+	return s, srv
+}
+
+func TestSenderHonoursRetryAfter(t *testing.T) {
+	s, srv := newTestSender(t)
+	srv.FailNext("sendMessage", 429, "Too Many Requests: retry after 1", 1)
+
+	start := time.Now()
+	if _, err := s.Send(context.Background(), tgbotapi.NewMessage(42, "hi")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("retried after %v, want at least retry_after=1s", d)
+	}
+	if n := len(srv.Calls("sendMessage")); n != 2 {
+		t.Errorf("sendMessage calls = %d, want 2", n)
+	}
+}
+
+func TestSenderPermanentError(t *testing.T) {
+	s, srv := newTestSender(t)
+	srv.FailNext("sendMessage", 403, "Forbidden: bot was blocked by the user", 0)
+
+	_, err := s.Send(context.Background(), tgbotapi.NewMessage(42, "hi"))
+	if !IsPermanent(err) {
+		t.Fatalf("err = %v, want permanent", err)
+	}
+	if n := len(srv.Calls("sendMessage")); n != 1 {
+		t.Errorf("sendMessage calls = %d, want 1 (no retries)", n)
+	}
+}
+
+// при загрузке файла tgbotapi не передаёт error_code — код берётся из описания
+func TestSenderPermanentUploadError(t *testing.T) {
+	s, srv := newTestSender(t)
+	srv.FailNext("sendPhoto", 403, "Forbidden: bot was blocked by the user", 0)
+
+	photo := tgbotapi.NewPhoto(42, tgbotapi.FileBytes{Name: "a.jpg", Bytes: []byte("jpg")})
+	_, err := s.Send(context.Background(), photo)
+	var se *SendError
+	if !errors.As(err, &se) || !se.Permanent || se.Code != 403 || se.ChatID != 42 {
+		t.Fatalf("err = %#v, want permanent 403 for chat 42", err)
+	}
+	if n := len(srv.Calls("sendPhoto")); n != 1 {
+		t.Errorf("sendPhoto calls = %d, want 1 (no retries)", n)
+	}
+}
+
+// повтор — такой же запрос к Telegram и тратит лимит чата
+func TestSenderRetryWaitsChatLimit(t *testing.T) {
+	s, srv := newTestSender(t)
+	srv.FailNext("sendMessage", 500, "Internal Server Error", 0)
+
+	const chatID = -100
+	if _, err := s.Send(context.Background(), tgbotapi.NewMessage(chatID, "hi")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if n := len(srv.Calls("sendMessage")); n != 2 {
+		t.Fatalf("sendMessage calls = %d, want 2", n)
+	}
+	// две попытки — два токена из groupChatBurst (за время backoff капает меньше одного)
+	if left := s.chats.Get(chatID).Tokens(); left > float64(groupChatBurst)-1.5 {
+		t.Errorf("chat tokens left = %.2f, want both attempts counted", left)
+	}
+}
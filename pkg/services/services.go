@@ -2,31 +2,27 @@ package services
 
 import (
 	"context"
-	"errors"
+	"time"
+
 	"github.com/Redarek/go-tg-bot-rest/pkg/models"
 	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
 )
 
-var ErrAlreadyClaimed = errors.New("already_claimed")
+var ErrAlreadyClaimed = repositories.ErrAlreadyClaimed
 
 type Service struct {
-	Repo *repositories.Repository
+	Repo   repositories.Store
+	policy *drawPolicy
 }
 
-func NewService(repo *repositories.Repository) *Service {
-	return &Service{Repo: repo}
+func NewService(repo repositories.Store) *Service {
+	return &Service{Repo: repo, policy: &drawPolicy{repo: repo, now: time.Now}}
 }
 
-func (s *Service) ClaimPromotion(ctx context.Context, userID, adminID int64) (models.Promotion, error) {
+// ClaimPromotion атомарно фиксирует клейм, выбирает скидку и ставит в outbox
+// сообщения, которые собирает prize. Проверки участия — в CheckDraw.
+func (s *Service) ClaimPromotion(ctx context.Context, userID, adminID int64,
+	prize func(models.Promotion) []models.OutboxMessage) (models.Promotion, error) {
 	// Админ может дергать бесконечно
-	if userID != adminID {
-		ok, err := s.Repo.TryClaim(ctx, userID)
-		if err != nil {
-			return models.Promotion{}, err
-		}
-		if !ok {
-			return models.Promotion{}, ErrAlreadyClaimed
-		}
-	}
-	return s.Repo.GetRandomPromotion(ctx)
+	return s.Repo.ClaimPromotion(ctx, userID, userID != adminID, prize)
 }
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+)
+
+// Как долго реплика держит настройки в кэше: своё изменение видно сразу,
+// изменение с другой реплики — не позже чем через settingsTTL
+const settingsTTL = 30 * time.Second
+
+// SettingDef описывает настройку из меню /settings
+type SettingDef struct {
+	Key   string
+	Title string
+	Hint  string // подсказка при вводе значения
+	Photo bool   // значение можно прислать фотографией — сохраняется её file_id
+
+	apply  func(s *models.Settings, v string) error
+	format func(s models.Settings) string
+}
+
+var SettingDefs = []SettingDef{
+	{
+		Key: "shop_url", Title: "Ссылка на сайт", Hint: "ссылка вида https://…",
+		apply: func(s *models.Settings, v string) error {
+			if !isWebURL(v) {
+				return fmt.Errorf("нужна ссылка вида https://…")
+			}
+			s.ShopURL = v
+			return nil
+		},
+		format: func(s models.Settings) string { return s.ShopURL },
+	},
+	{
+		Key: "sub_channel_id", Title: "ID канала для подписки", Hint: "число вида -100…, 0 — не проверять подписку",
+		apply: func(s *models.Settings, v string) error {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("нужно число вида -100…")
+			}
+			s.SubChannelID = id
+			return nil
+		},
+		format: func(s models.Settings) string { return strconv.FormatInt(s.SubChannelID, 10) },
+	},
+	{
+		Key: "sub_channel_link", Title: "Ссылка на канал", Hint: "@channel или https://t.me/…",
+		apply: func(s *models.Settings, v string) error {
+			s.SubChannelLink = v
+			return nil
+		},
+		format: func(s models.Settings) string { return s.SubChannelLink },
+	},
+	{
+		Key: "draw_delay", Title: "Пауза перед призом", Hint: "длительность: 2s, 1500ms (0–30s)",
+		apply: func(s *models.Settings, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 || d > 30*time.Second {
+				return fmt.Errorf("нужна длительность от 0s до 30s, например 2s")
+			}
+			s.DrawDelay = d
+			return nil
+		},
+		format: func(s models.Settings) string { return s.DrawDelay.String() },
+	},
+	{
+		Key: "start_image", Title: "Картинка /start", Hint: "пришлите фото или ссылку https://…", Photo: true,
+		apply: func(s *models.Settings, v string) error {
+			if v == "" || strings.ContainsAny(v, " \n") {
+				return fmt.Errorf("нужна фотография или ссылка на картинку")
+			}
+			if strings.HasPrefix(v, "http") && !isWebURL(v) {
+				return fmt.Errorf("нужна ссылка вида https://…")
+			}
+			s.StartImage = v
+			return nil
+		},
+		format: func(s models.Settings) string {
+			if s.StartImage == "" {
+				return "встроенная"
+			}
+			return s.StartImage
+		},
+	},
+	{
+		Key: "start_buttons", Title: "Кнопки /start", Hint: "по кнопке на строку: Текст | https://…",
+		apply: func(s *models.Settings, v string) error {
+			var list []models.LinkButton
+			for i, line := range strings.Split(v, "\n") {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				text, link, ok := strings.Cut(line, "|")
+				text, link = strings.TrimSpace(text), strings.TrimSpace(link)
+				if !ok || text == "" || !isButtonURL(link) {
+					return fmt.Errorf("строка %d: нужно «Текст | https://…»", i+1)
+				}
+				list = append(list, models.LinkButton{Text: text, URL: link})
+			}
+			if len(list) > 8 {
+				return fmt.Errorf("не больше 8 кнопок")
+			}
+			s.StartButtons = list
+			return nil
+		},
+		format: func(s models.Settings) string {
+			lines := make([]string, 0, len(s.StartButtons))
+			for _, b := range s.StartButtons {
+				lines = append(lines, b.Text+" | "+b.URL)
+			}
+			return strings.Join(lines, "\n")
+		},
+	},
+	{
+		Key: "max_user_id", Title: "Макс. ID аккаунта", Hint: "число; ID растут со временем, у новых аккаунтов они больше. 0 — не проверять",
+		apply: func(s *models.Settings, v string) error {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id < 0 {
+				return fmt.Errorf("нужно неотрицательное число")
+			}
+			s.MaxUserID = id
+			return nil
+		},
+		format: func(s models.Settings) string { return strconv.FormatInt(s.MaxUserID, 10) },
+	},
+	{
+		Key: "require_username", Title: "Нужен @username", Hint: "да или нет",
+		apply: func(s *models.Settings, v string) (err error) {
+			s.RequireUsername, err = parseYesNo(v)
+			return err
+		},
+		format: func(s models.Settings) string { return formatYesNo(s.RequireUsername) },
+	},
+	{
+		Key: "require_premium", Title: "Нужен Telegram Premium", Hint: "да или нет",
+		apply: func(s *models.Settings, v string) (err error) {
+			s.RequirePremium, err = parseYesNo(v)
+			return err
+		},
+		format: func(s models.Settings) string { return formatYesNo(s.RequirePremium) },
+	},
+	{
+		Key: "min_start_age", Title: "Пауза после /start", Hint: "длительность: 10m, 1h; 0 — не проверять",
+		apply: func(s *models.Settings, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 || d > 7*24*time.Hour {
+				return fmt.Errorf("нужна длительность от 0s до 168h, например 10m")
+			}
+			s.MinStartAge = d
+			return nil
+		},
+		format: func(s models.Settings) string { return s.MinStartAge.String() },
+	},
+	{
+		Key: "draw_flood", Title: "Лимит попыток розыгрыша", Hint: "попыток/период: 5/10m; 0 — без лимита",
+		apply: func(s *models.Settings, v string) error {
+			if v == "0" {
+				s.DrawFlood = models.Rate{}
+				return nil
+			}
+			n, per, ok := strings.Cut(v, "/")
+			count, err := strconv.Atoi(n)
+			d, derr := time.ParseDuration(per)
+			if !ok || err != nil || derr != nil || count < 1 || d <= 0 {
+				return fmt.Errorf("нужно «попыток/период», например 5/10m")
+			}
+			s.DrawFlood = models.Rate{N: count, Per: d}
+			return nil
+		},
+		format: func(s models.Settings) string {
+			if s.DrawFlood.N == 0 {
+				return "0"
+			}
+			return fmt.Sprintf("%d/%s", s.DrawFlood.N, s.DrawFlood.Per)
+		},
+	},
+	{
+		Key: "captcha", Title: "Проверка «не бот»", Hint: "emoji — найти предмет, math — сложить числа, нет — без проверки",
+		apply: func(s *models.Settings, v string) error {
+			switch strings.ToLower(v) {
+			case CaptchaEmoji, CaptchaMath:
+				s.Captcha = strings.ToLower(v)
+			case "нет", "no", "off", "0":
+				s.Captcha = ""
+			default:
+				return fmt.Errorf("нужно emoji, math или нет")
+			}
+			return nil
+		},
+		format: func(s models.Settings) string {
+			if s.Captcha == "" {
+				return "нет"
+			}
+			return s.Captcha
+		},
+	},
+}
+
+func parseYesNo(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "да", "yes", "on", "true", "1":
+		return true, nil
+	case "нет", "no", "off", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("нужно «да» или «нет»")
+}
+
+func formatYesNo(b bool) string {
+	if b {
+		return "да"
+	}
+	return "нет"
+}
+
+func isWebURL(v string) bool {
+	u, err := url.Parse(v)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isButtonURL — ссылки, которые Telegram принимает в url-кнопках
+func isButtonURL(v string) bool {
+	return isWebURL(v) || strings.HasPrefix(v, "tg://")
+}
+
+// SettingDefByKey — описание настройки по ключу
+func SettingDefByKey(key string) (SettingDef, bool) {
+	for _, d := range SettingDefs {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return SettingDef{}, false
+}
+
+// Value — текущее значение настройки в текстовом виде
+func (d SettingDef) Value(s models.Settings) string { return d.format(s) }
+
+// Settings — настройки времени выполнения: значения по умолчанию из env,
+// поверх них — переопределения админа из БД. Читаются через кэш.
+type Settings struct {
+	repo     repositories.SettingsStore
+	defaults models.Settings
+	log      *slog.Logger
+	cache    cached[models.Settings]
+}
+
+func NewSettings(repo repositories.SettingsStore, defaults models.Settings, logger *slog.Logger) *Settings {
+	s := &Settings{repo: repo, defaults: defaults, log: logger.With("component", "settings")}
+	s.cache.ttl, s.cache.val = settingsTTL, defaults
+	return s
+}
+
+// Get — актуальные настройки; если БД недоступна, отдаём последние известные
+func (s *Settings) Get(ctx context.Context) models.Settings {
+	st, err := s.cache.get(ctx, s.load)
+	if err != nil {
+		s.log.Error("load settings", "err", err)
+	}
+	return st
+}
+
+func (s *Settings) load(ctx context.Context) (models.Settings, error) {
+	raw, err := s.repo.GetSettings(ctx)
+	if err != nil {
+		return models.Settings{}, err
+	}
+	st := s.defaults
+	for _, d := range SettingDefs {
+		v, ok := raw[d.Key]
+		if !ok {
+			continue
+		}
+		if err = d.apply(&st, v); err != nil {
+			s.log.Warn("invalid stored setting ignored", "key", d.Key, "err", err)
+		}
+	}
+	return st, nil
+}
+
+// Set проверяет и сохраняет значение; ошибка проверки понятна админу
+func (s *Settings) Set(ctx context.Context, key, value string) error {
+	d, ok := SettingDefByKey(key)
+	if !ok {
+		return fmt.Errorf("неизвестная настройка %q", key)
+	}
+	value = strings.TrimSpace(value)
+	probe := s.defaults
+	if err := d.apply(&probe, value); err != nil {
+		return err
+	}
+	if err := s.repo.SetSetting(ctx, key, value); err != nil {
+		return err
+	}
+	s.Invalidate()
+	return nil
+}
+
+// Reset возвращает значение по умолчанию (из env)
+func (s *Settings) Reset(ctx context.Context, key string) error {
+	if _, ok := SettingDefByKey(key); !ok {
+		return fmt.Errorf("неизвестная настройка %q", key)
+	}
+	if err := s.repo.DeleteSetting(ctx, key); err != nil {
+		return err
+	}
+	s.Invalidate()
+	return nil
+}
+
+// Invalidate сбрасывает кэш — следующий Get перечитает БД
+func (s *Settings) Invalidate() { s.cache.invalidate() }
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/models"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+)
+
+func TestSettings(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewMemory()
+	defaults := models.Settings{ShopURL: "https://env.example", DrawDelay: 2 * time.Second}
+	s := NewSettings(repo, defaults, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if got := s.Get(ctx); !reflect.DeepEqual(got, defaults) {
+		t.Fatalf("Get = %+v, want defaults", got)
+	}
+
+	if err := s.Set(ctx, "draw_delay", "forever"); err == nil {
+		t.Error("Set invalid draw_delay: want error")
+	}
+	if err := s.Set(ctx, "shop_url", "ftp://x"); err == nil {
+		t.Error("Set invalid shop_url: want error")
+	}
+	if err := s.Set(ctx, "nope", "1"); err == nil {
+		t.Error("Set unknown key: want error")
+	}
+
+	// своё изменение видно сразу
+	if err := s.Set(ctx, "shop_url", " https://admin.example "); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get(ctx).ShopURL; got != "https://admin.example" {
+		t.Errorf("ShopURL = %q after Set", got)
+	}
+
+	// изменение с другой реплики — после сброса кэша
+	_ = repo.SetSetting(ctx, "draw_delay", "5s")
+	if got := s.Get(ctx).DrawDelay; got != 2*time.Second {
+		t.Errorf("DrawDelay = %v, want cached 2s", got)
+	}
+	s.Invalidate()
+	if got := s.Get(ctx).DrawDelay; got != 5*time.Second {
+		t.Errorf("DrawDelay = %v after Invalidate, want 5s", got)
+	}
+
+	// битое значение в БД игнорируется
+	_ = repo.SetSetting(ctx, "sub_channel_id", "abc")
+	s.Invalidate()
+	if got := s.Get(ctx).SubChannelID; got != 0 {
+		t.Errorf("SubChannelID = %d, want default for invalid stored value", got)
+	}
+
+	if err := s.Reset(ctx, "shop_url"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get(ctx).ShopURL; got != defaults.ShopURL {
+		t.Errorf("ShopURL = %q after Reset, want %q", got, defaults.ShopURL)
+	}
+}
+
+func TestStartScreenSettings(t *testing.T) {
+	ctx := context.Background()
+	s := NewSettings(repositories.NewMemory(), models.Settings{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, bad := range []string{"Сайт", "Сайт | ftp://x", " | https://example.com"} {
+		if err := s.Set(ctx, "start_buttons", bad); err == nil {
+			t.Errorf("Set start_buttons %q: want error", bad)
+		}
+	}
+	if err := s.Set(ctx, "start_buttons", "Сайт | https://example.com\n\nКанал|tg://resolve?domain=x"); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.LinkButton{{Text: "Сайт", URL: "https://example.com"}, {Text: "Канал", URL: "tg://resolve?domain=x"}}
+	if got := s.Get(ctx).StartButtons; !reflect.DeepEqual(got, want) {
+		t.Errorf("StartButtons = %+v, want %+v", got, want)
+	}
+
+	if err := s.Set(ctx, "start_image", "http://"); err == nil {
+		t.Error("Set start_image with a broken URL: want error")
+	}
+	if err := s.Set(ctx, "start_image", "AgACAgIAAxkBAAIB"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get(ctx).StartImage; got != "AgACAgIAAxkBAAIB" {
+		t.Errorf("StartImage = %q", got)
+	}
+}
+
+func TestDrawPolicySettings(t *testing.T) {
+	ctx := context.Background()
+	s := NewSettings(repositories.NewMemory(), models.Settings{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for key, bad := range map[string]string{
+		"max_user_id":      "-1",
+		"require_username": "maybe",
+		"min_start_age":    "soon",
+		"draw_flood":       "5 per minute",
+	} {
+		if err := s.Set(ctx, key, bad); err == nil {
+			t.Errorf("Set %s %q: want error", key, bad)
+		}
+	}
+	for key, v := range map[string]string{
+		"max_user_id":      "7000000000",
+		"require_username": "да",
+		"require_premium":  "off",
+		"min_start_age":    "10m",
+		"draw_flood":       "3/1h",
+	} {
+		if err := s.Set(ctx, key, v); err != nil {
+			t.Fatalf("Set %s %q: %v", key, v, err)
+		}
+	}
+	want := models.Settings{MaxUserID: 7000000000, RequireUsername: true, MinStartAge: 10 * time.Minute,
+		DrawFlood: models.Rate{N: 3, Per: time.Hour}}
+	if got := s.Get(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+	if d, _ := SettingDefByKey("draw_flood"); d.Value(want) != "3/1h0m0s" {
+		t.Errorf("draw_flood value = %q", d.Value(want))
+	}
+}
+
+// slowSettings — хранилище, чтение из которого ждёт release и может вернуть err
+type slowSettings struct {
+	*repositories.Memory
+	release chan struct{}
+	err     error
+}
+
+func (s *slowSettings) GetSettings(ctx context.Context) (map[string]string, error) {
+	<-s.release
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.Memory.GetSettings(ctx)
+}
+
+// пока одна реплика перечитывает БД, остальные получают прежние настройки,
+// а сбой чтения не сбрасывает их к значениям из env
+func TestSettingsRefreshDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	repo := &slowSettings{Memory: repositories.NewMemory(), release: make(chan struct{})}
+	_ = repo.SetSetting(ctx, "shop_url", "https://admin.example")
+	s := NewSettings(repo, models.Settings{ShopURL: "https://env.example"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	close(repo.release)
+	if got := s.Get(ctx).ShopURL; got != "https://admin.example" {
+		t.Fatalf("ShopURL = %q", got)
+	}
+
+	repo.release, repo.err = make(chan struct{}), errors.New("db down")
+	s.Invalidate()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Get(ctx) // зависает в GetSettings
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	got := make(chan string, 1)
+	go func() { got <- s.Get(ctx).ShopURL }()
+	select {
+	case v := <-got:
+		if v != "https://admin.example" {
+			t.Errorf("ShopURL during refresh = %q, want the cached value", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get blocked behind a slow refresh")
+	}
+
+	close(repo.release)
+	<-done
+	if v := s.Get(ctx).ShopURL; v != "https://admin.example" {
+		t.Errorf("ShopURL after failed refresh = %q, want the cached value", v)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+	"github.com/Redarek/go-tg-bot-rest/pkg/repositories"
+	"github.com/Redarek/go-tg-bot-rest/pkg/templates"
+)
+
+// Templates — тексты сообщений: встроенные шаблоны, поверх них — правки
+// админа из БД, отдельно для каждого языка. Кэшируются так же, как Settings.
+type Templates struct {
+	repo  repositories.TemplatesStore
+	log   *slog.Logger
+	cache cached[map[string]map[string]string] // locale → name → body
+}
+
+func NewTemplates(repo repositories.TemplatesStore, logger *slog.Logger) *Templates {
+	t := &Templates{repo: repo, log: logger.With("component", "templates")}
+	t.cache.ttl = settingsTTL
+	return t
+}
+
+// Source — текущий текст шаблона на языке locale (правка админа или встроенный)
+func (t *Templates) Source(ctx context.Context, locale, name string) string {
+	if body, ok := t.overrides(ctx)[locale][name]; ok {
+		return body
+	}
+	return templates.Default(locale, name)
+}
+
+// Customized — изменён ли шаблон админом на языке locale
+func (t *Templates) Customized(ctx context.Context, locale, name string) bool {
+	_, ok := t.overrides(ctx)[locale][name]
+	return ok
+}
+
+// Render собирает текст сообщения на языке пользователя из ctx. Если шаблон
+// админа не выполнился, используется встроенный — пользователь всегда
+// получает ответ.
+func (t *Templates) Render(ctx context.Context, name string, data templates.Data) string {
+	d, ok := templates.Lookup(name)
+	if !ok {
+		t.log.Error("unknown template", "name", name)
+		return ""
+	}
+	locale := i18n.FromContext(ctx)
+	if body, ok := t.overrides(ctx)[locale][name]; ok {
+		out, err := templates.Render(d, body, data)
+		if err == nil {
+			return out
+		}
+		t.log.Error("render custom template, falling back to default", "name", name, "locale", locale, "err", err)
+	}
+	out, err := templates.Render(d, templates.Default(locale, name), data)
+	if err != nil {
+		t.log.Error("render default template", "name", name, "locale", locale, "err", err)
+	}
+	return out
+}
+
+// Check проверяет шаблон на тестовых данных и возвращает результат
+func (t *Templates) Check(name, body string) (string, error) {
+	d, ok := templates.Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("неизвестный шаблон %q", name)
+	}
+	return templates.Render(d, strings.TrimSpace(body), templates.SampleData)
+}
+
+// Set сохраняет шаблон для языка locale, предварительно проверив его
+func (t *Templates) Set(ctx context.Context, locale, name, body string) error {
+	if _, ok := i18n.Match(locale); !ok {
+		return fmt.Errorf("неизвестный язык %q", locale)
+	}
+	if _, err := t.Check(name, body); err != nil {
+		return err
+	}
+	if err := t.repo.SetTemplate(ctx, locale, name, strings.TrimSpace(body)); err != nil {
+		return err
+	}
+	t.Invalidate()
+	return nil
+}
+
+// Reset возвращает встроенный текст на языке locale
+func (t *Templates) Reset(ctx context.Context, locale, name string) error {
+	if _, ok := templates.Lookup(name); !ok {
+		return fmt.Errorf("неизвестный шаблон %q", name)
+	}
+	if err := t.repo.DeleteTemplate(ctx, locale, name); err != nil {
+		return err
+	}
+	t.Invalidate()
+	return nil
+}
+
+// Invalidate сбрасывает кэш — следующее обращение перечитает БД
+func (t *Templates) Invalidate() { t.cache.invalidate() }
+
+func (t *Templates) overrides(ctx context.Context) map[string]map[string]string {
+	m, err := t.cache.get(ctx, t.repo.GetTemplates)
+	if err != nil {
+		t.log.Error("load templates", "err", err)
+	}
+	return m
+}
@@ -0,0 +1,110 @@
+// Package templates — тексты сообщений бота: встроенные значения по умолчанию
+// и рендеринг text/template с экранированием данных под HTML-разметку Telegram
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+)
+
+//go:embed defaults/*/*.tmpl
+var defaultsFS embed.FS
+
+// Def описывает шаблон из меню /templates
+type Def struct {
+	Name  string
+	Title string
+	HTML  bool     // отправляется с ParseMode HTML; данные экранируются
+	Vars  []string // доступные поля Data — для подсказки админу
+}
+
+var Defs = []Def{
+	{Name: "start", Title: "Приветствие (/start)", HTML: true, Vars: []string{"FirstName", "ShopURL", "ChannelLink"}},
+	{Name: "subscribe_required", Title: "Нужна подписка", HTML: true, Vars: []string{"FirstName", "ChannelLink"}},
+	{Name: "prize", Title: "Приз", HTML: true, Vars: []string{"FirstName", "Promotion", "Value"}},
+	{Name: "prize_cta", Title: "После приза", HTML: true, Vars: []string{"FirstName", "Promotion", "Value", "ShopURL"}},
+	{Name: "already_claimed", Title: "Уже участвовал", HTML: true, Vars: []string{"FirstName", "ShopURL"}},
+	{Name: "no_promotions", Title: "Скидок нет", HTML: true, Vars: []string{"FirstName"}},
+	{Name: "draw_denied", Title: "Участие запрещено", HTML: true, Vars: []string{"FirstName"}},
+	{Name: "draw_wait", Title: "Рано или слишком часто", HTML: true, Vars: []string{"FirstName"}},
+	{Name: "error", Title: "Ошибка", HTML: true, Vars: nil},
+	{Name: "shop_button", Title: "Кнопка ссылки на сайт", Vars: nil},
+	{Name: "draw_button", Title: "Кнопка розыгрыша (/start)", Vars: nil},
+}
+
+// Data — поля, доступные в шаблонах как {{.FirstName}}, {{.Value}} и т.д.
+type Data struct {
+	FirstName   string
+	ShopURL     string
+	ChannelLink string
+	Promotion   string // название скидки
+	Value       string // значение скидки
+}
+
+// SampleData — данные для проверки шаблона при сохранении
+var SampleData = Data{
+	FirstName:   "Анна",
+	ShopURL:     "https://example.com",
+	ChannelLink: "@channel",
+	Promotion:   "Десятка",
+	Value:       "-10%",
+}
+
+// Lookup — описание шаблона по имени
+func Lookup(name string) (Def, bool) {
+	for _, d := range Defs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Def{}, false
+}
+
+// Default — встроенный текст шаблона на языке locale; если перевода нет —
+// на i18n.Fallback
+func Default(locale, name string) string {
+	b, err := defaultsFS.ReadFile("defaults/" + locale + "/" + name + ".tmpl")
+	if err != nil {
+		if b, err = defaultsFS.ReadFile("defaults/" + i18n.Fallback + "/" + name + ".tmpl"); err != nil {
+			return ""
+		}
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+// Render выполняет шаблон body. В HTML-шаблонах значения Data экранируются,
+// так что название скидки с «<» или «&» не ломает разметку.
+func Render(d Def, body string, data Data) (string, error) {
+	t, err := template.New(d.Name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+	if d.HTML {
+		data = escape(data)
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(buf.String())
+	if out == "" {
+		return "", fmt.Errorf("шаблон %s дал пустой текст", d.Name)
+	}
+	return out, nil
+}
+
+func escape(d Data) Data {
+	return Data{
+		FirstName:   html.EscapeString(d.FirstName),
+		ShopURL:     html.EscapeString(d.ShopURL),
+		ChannelLink: html.EscapeString(d.ChannelLink),
+		Promotion:   html.EscapeString(d.Promotion),
+		Value:       html.EscapeString(d.Value),
+	}
+}
@@ -0,0 +1,53 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/Redarek/go-tg-bot-rest/pkg/i18n"
+)
+
+func TestDefaultsRender(t *testing.T) {
+	for _, loc := range i18n.Supported {
+		for _, d := range Defs {
+			b, err := defaultsFS.ReadFile("defaults/" + loc + "/" + d.Name + ".tmpl")
+			if err != nil {
+				t.Errorf("%s/%s: no embedded default", loc, d.Name)
+				continue
+			}
+			if _, err = Render(d, string(b), SampleData); err != nil {
+				t.Errorf("%s/%s: %v", loc, d.Name, err)
+			}
+		}
+	}
+}
+
+func TestDefaultFallsBack(t *testing.T) {
+	if got, want := Default("xx", "error"), Default(i18n.Fallback, "error"); got != want || got == "" {
+		t.Errorf("Default(xx) = %q, want fallback %q", got, want)
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	d, _ := Lookup("prize")
+	got, err := Render(d, "<b>{{.Value}}</b>", Data{Value: "<-10% & more>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<b>&lt;-10% &amp; more&gt;</b>"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	plain, _ := Lookup("shop_button")
+	if got, _ = Render(plain, "{{.Value}}", Data{Value: "A & B"}); got != "A & B" {
+		t.Errorf("plain Render = %q, want unescaped", got)
+	}
+}
+
+func TestRenderRejectsBadTemplates(t *testing.T) {
+	d, _ := Lookup("prize")
+	for _, body := range []string{"{{.Value", "{{.Unknown}}", "  {{\"\"}} "} {
+		if _, err := Render(d, body, SampleData); err == nil {
+			t.Errorf("Render(%q): want error", body)
+		}
+	}
+}
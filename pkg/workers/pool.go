@@ -0,0 +1,113 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Pool — пул воркеров с шардированием по пользователю: апдейты одного
+// пользователя обрабатываются строго по очереди одним воркером, разные
+// пользователи — параллельно
+type Pool struct {
+	shards  []chan tgbotapi.Update
+	handle  func(tgbotapi.Update)
+	running atomic.Int32
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewPool: size воркеров, queue — суммарная ёмкость очередей всех шардов
+func NewPool(size, queue int, handle func(tgbotapi.Update)) *Pool {
+	perShard := queue / size
+	if perShard < 1 {
+		perShard = 1
+	}
+	p := &Pool{shards: make([]chan tgbotapi.Update, size), handle: handle}
+	for i := range p.shards {
+		p.shards[i] = make(chan tgbotapi.Update, perShard)
+	}
+	return p
+}
+
+func (p *Pool) Start() {
+	for _, ch := range p.shards {
+		p.wg.Add(1)
+		p.running.Add(1)
+		go func(ch chan tgbotapi.Update) {
+			defer p.wg.Done()
+			defer p.running.Add(-1)
+			for upd := range ch {
+				p.handle(upd)
+			}
+		}(ch)
+	}
+}
+
+// Enqueue кладёт апдейт в очередь его шарда, ожидая свободного места
+func (p *Pool) Enqueue(ctx context.Context, upd tgbotapi.Update) error {
+	ch := p.shards[shardIndex(ShardKey(upd), len(p.shards))]
+	select {
+	case ch <- upd:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown закрывает очереди и ждёт, пока воркеры доработают то, что уже
+// в них, но не дольше ctx
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.once.Do(func() {
+		for _, ch := range p.shards {
+			close(ch)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending — сколько апдейтов ждёт в очередях шардов
+func (p *Pool) Pending() int {
+	n := 0
+	for _, ch := range p.shards {
+		n += len(ch)
+	}
+	return n
+}
+
+func (p *Pool) Size() int { return len(p.shards) }
+
+// Depth — ёмкость очереди одного шарда
+func (p *Pool) Depth() int { return cap(p.shards[0]) }
+
+// Running — сколько воркеров сейчас живо (для /readyz)
+func (p *Pool) Running() int { return int(p.running.Load()) }
+
+// ShardKey — ключ упорядочивания: пользователь, иначе чат, иначе сам апдейт
+func ShardKey(upd tgbotapi.Update) int64 {
+	if u := upd.SentFrom(); u != nil {
+		return u.ID
+	}
+	if c := upd.FromChat(); c != nil {
+		return c.ID
+	}
+	return int64(upd.UpdateID)
+}
+
+func shardIndex(key int64, n int) int {
+	// fibonacci hashing: соседние id не должны падать в соседние шарды пачками
+	h := uint64(key) * 0x9E3779B97F4A7C15
+	return int(h % uint64(n))
+}
@@ -0,0 +1,123 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func userUpdate(id int, userID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{
+		From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: userID}}}
+}
+
+// Апдейты двух пользователей вперемешку: у каждого порядок сохраняется,
+// а пользователи в разных шардах обрабатываются параллельно
+func TestPoolKeepsUserOrder(t *testing.T) {
+	const size, perUser = 4, 20
+	alice := int64(1001)
+	bob := alice + 1
+	for shardIndex(bob, size) == shardIndex(alice, size) {
+		bob++
+	}
+
+	var mu sync.Mutex
+	got := map[int64][]int{}
+	p := NewPool(size, 64, func(upd tgbotapi.Update) {
+		// у Алисы обработка медленнее: без шардов Боб обгонял бы её в общей очереди
+		if upd.SentFrom().ID == alice {
+			time.Sleep(time.Millisecond)
+		}
+		mu.Lock()
+		got[upd.SentFrom().ID] = append(got[upd.SentFrom().ID], upd.UpdateID)
+		mu.Unlock()
+	})
+	p.Start()
+
+	want := map[int64][]int{}
+	for i := 0; i < perUser; i++ {
+		for _, u := range []int64{alice, bob} {
+			id := len(want[alice]) + len(want[bob]) + 1
+			want[u] = append(want[u], id)
+			if err := p.Enqueue(context.Background(), userUpdate(id, u)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handled order = %v, want %v", got, want)
+	}
+}
+
+// Shutdown дорабатывает уже поставленное в очередь; если воркер завис,
+// выходит по ctx, а Pending показывает, что осталось необработанным
+func TestPoolShutdown(t *testing.T) {
+	t.Run("drains", func(t *testing.T) {
+		var mu sync.Mutex
+		handled := 0
+		p := NewPool(2, 16, func(tgbotapi.Update) {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			handled++
+			mu.Unlock()
+		})
+		p.Start()
+		for i := 1; i <= 10; i++ {
+			if err := p.Enqueue(context.Background(), userUpdate(i, int64(i%3))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if handled != 10 || p.Pending() != 0 || p.Running() != 0 {
+			t.Errorf("after shutdown: handled %d, pending %d, running %d; want 10, 0, 0",
+				handled, p.Pending(), p.Running())
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		p := NewPool(1, 8, func(tgbotapi.Update) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+		})
+		p.Start()
+		for i := 1; i <= 3; i++ {
+			if err := p.Enqueue(context.Background(), userUpdate(i, 1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Shutdown with a stuck worker = %v, want deadline exceeded", err)
+		}
+		if p.Pending() != 2 || p.Running() != 1 {
+			t.Errorf("pending %d, running %d; want 2, 1", p.Pending(), p.Running())
+		}
+
+		// воркер отвис — повторный Shutdown дожидается остатка
+		close(release)
+		if err := p.Shutdown(context.Background()); err != nil {
+			t.Fatalf("second Shutdown: %v", err)
+		}
+		if p.Pending() != 0 || p.Running() != 0 {
+			t.Errorf("pending %d, running %d after drain; want 0, 0", p.Pending(), p.Running())
+		}
+	})
+}